@@ -5,14 +5,24 @@ package ansibump
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
 	"html"
+	"image/color"
 	"io"
+	"iter"
+	"math"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/width"
 )
 
 var (
@@ -26,33 +36,72 @@ var (
 	ErrUnknownCSI = errors.New("unrecognized CSI final byte")
 	ErrUnknownCtr = errors.New("unrecognized control byte")
 	ErrUnknownEsc = errors.New("unrecognized ESC sequence after ESC")
+
+	ErrUnknownPalette = errors.New("unknown color palette")
+	ErrInvalidWidth   = errors.New("width cannot be negative")
+	ErrCubeSteps      = errors.New("color cube steps must be between 0 and 255")
 )
 
+// DecodeError is the error returned by the strict mode of [Decoder.Read]
+// for a malformed or unrecognized sequence.
+type DecodeError struct {
+	Offset   int    // Offset is the byte offset of the start of the sequence in the Reader
+	Sequence []byte // Sequence are the bytes of the sequence
+	Err      error  // Err is the underlying error, which wraps one of the package errors such as ErrUnknownCSI
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("offset %d: %q: %s", e.Offset, e.Sequence, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
 const (
 	NUL = 0x00 // NUL is an ASCII null character
+	BEL = 0x07 // BEL is the bell control character code
+	BS  = 0x08 // BS is the backspace control character code
+	HT  = 0x09 // HT is the horizontal tab control character code
+	VT  = 0x0b // VT is the vertical tab control character code
+	FF  = 0x0c // FF is the form feed control character code
+	SO  = 0x0e // SO is the shift out control character code that selects the G1 character set
+	SI  = 0x0f // SI is the shift in control character code that selects the G0 character set
 	EOF = 0x1a // EOF is the MS-DOS end-of-file character value
 	ESC = 0x1b // ESC is the escape control character code
+	DEL = 0x7f // DEL is the delete control character code
+
+	NoWrap = -1 // NoWrap is the width value that never wraps the text
 
-	Reset        = 0
-	Bold         = 1
-	NotBold      = 21
-	NotBoldFaint = 22
-	Underline    = 4
-	NotUnderline = 24
-	Invert       = 7
-	NotInvert    = 27
-	DefaultFG    = 39
-	DefaultBG    = 49
-	FG1st        = 30
-	FGEnd        = 37
-	BG1st        = 40
-	BGEnd        = 47
-	BrightFG1st  = 90
-	BrightFGEnd  = 97
-	BrightBG1st  = 100
-	BrightBGEnd  = 107
-	SetFG        = 38
-	SetBG        = 48
+	Reset = 0
+	Bold  = 1
+	Faint = 2
+	// NotBold is the SGR parameter that once reset the bold intensity.
+	//
+	// Deprecated: 21 is now decoded as DoubleUnderline, as defined by ECMA-48,
+	// and no longer resets the bold intensity. Use NormalIntensity (22) instead.
+	NotBold         = 21
+	NormalIntensity = 22 // NormalIntensity resets both the bold and faint intensities
+	NotBoldFaint    = NormalIntensity
+	Underline       = 4
+	DoubleUnderline = 21
+	NotUnderline    = 24
+	Invert          = 7
+	NotInvert       = 27
+	Conceal         = 8
+	NotConceal      = 28
+	DefaultFG       = 39
+	DefaultBG       = 49
+	FG1st           = 30
+	FGEnd           = 37
+	BG1st           = 40
+	BGEnd           = 47
+	BrightFG1st     = 90
+	BrightFGEnd     = 97
+	BrightBG1st     = 100
+	BrightBGEnd     = 107
+	SetFG           = 38
+	SetBG           = 48
 )
 
 // Palette sets the ANSI 4-bit color codes to a colorset of RGB values.
@@ -68,6 +117,30 @@ const (
 	DP2                    // DP2 is a Commodore Amiga era Deluxe Paint II colorset that mimics the colors of CGA16
 )
 
+// BoldMode sets how the bold SGR attribute is rendered.
+// Historically, terminals and the IBM PC displayed bold text using the bright variant of the color,
+// while modern terminals often use a heavier font weight.
+type BoldMode uint
+
+const (
+	BoldBright BoldMode = iota // BoldBright uses the lighter variant of the color, which is the default
+	BoldWeight                 // BoldWeight uses the bold font-weight and keeps the color
+	BoldBoth                   // BoldBoth uses both the lighter variant of the color and the bold font-weight
+)
+
+// colors returns the 16 colors of the palette, which are blank for an unknown palette.
+func (p Palette) colors() Colors {
+	switch p {
+	case CGA16:
+		return CGA()
+	case Xterm16:
+		return Xterm()
+	case DP2:
+		return DPaint2()
+	}
+	return Colors{}
+}
+
 // Color code represented as hexadecimal numeric value.
 // These are often 6 digit values RRGGBB (red, green, blue),
 // however, certain values can be shortened to 3 digit values.
@@ -127,21 +200,38 @@ const (
 )
 
 // BG returns the CSS background-color property and color value.
+// An empty string is returned when the color is not a valid hexadecimal value.
 func (c Color) BG() string {
-	if c == "" {
+	if !c.Valid() {
 		return ""
 	}
 	return "background-color:#" + string(c) + ";"
 }
 
 // FG returns the CSS color property and color value.
+// An empty string is returned when the color is not a valid hexadecimal value.
 func (c Color) FG() string {
-	if c == "" {
+	if !c.Valid() {
 		return ""
 	}
 	return "color:#" + string(c) + ";"
 }
 
+// Valid reports whether the color is a 3 or 6 digit hexadecimal value.
+func (c Color) Valid() bool {
+	const short, long = 3, 6
+	if len(c) != short && len(c) != long {
+		return false
+	}
+	for _, r := range c {
+		hex := ('0' <= r && r <= '9') || ('a' <= r && r <= 'f') || ('A' <= r && r <= 'F')
+		if !hex {
+			return false
+		}
+	}
+	return true
+}
+
 type Colors [16]Color
 
 // DefaultFG will return the "white" variant of the color palette,
@@ -184,29 +274,240 @@ type Attribute struct {
 	FG        string // FG is a foreground hex color like "rrggbb" or (no leading #) or empty for default
 	BG        string // BG is a background hex color like "rrggbb"
 	Bold      bool   // Bold toggles a lighter color variation
+	Faint     bool   // Faint toggles a darker color variation
 	Underline bool   // Underline toggles a underline text decoration
+	Double    bool   // Double toggles a double underline text decoration
 	Inverse   bool   // Inverse swaps the background and foreground colors
+	Conceal   bool   // Conceal hides the text by using the background color for the foreground
+}
+
+// saved is the cursor position and attribute stored by the ESC 7 save cursor sequence.
+type saved struct {
+	x, y int
+	attr Attribute
 }
 
 // Decoder maintains the screen buffer and cursor state while parsing ANSI.
 type Decoder struct {
-	charset        *charmap.Charmap
-	palette        Palette
-	buffer         [][]cell
-	currentLine    []cell
-	x, y           int
-	savedX, savedY int
-	width          int
-	defaultFG      Color
-	defaultBG      Color
-	amigaParser    bool
-	strict         bool
+	charset         *charmap.Charmap
+	palette         Palette
+	buffer          [][]cell
+	currentLine     []cell
+	attr            Attribute // attr is the current attribute applied to subsequent characters
+	last            *cell     // last is the last written character, or nil when nothing is written
+	wrapped         bool      // wrapped reports whether the cursor moved to the current line by autowrap
+	x, y            int
+	savedX, savedY  int
+	saved           *saved // saved is the cursor and attribute stored by ESC 7, or nil when nothing is stored
+	g0              byte   // g0 is the final byte of the G0 character set designated by ESC (, or 0 for none
+	g1              byte   // g1 is the final byte of the G1 character set designated by ESC ), or 0 for none
+	shiftOut        bool   // shiftOut is true when the SO control selects the G1 character set
+	cursorShape     int    // cursorShape is the cursor shape requested by the DECSCUSR sequence
+	width           int
+	baseWidth       int // baseWidth is the configured width that Reset restores after AutoWidth
+	tabWidth        int
+	maxBlankRuns    int
+	maxSpans        int
+	tabStops        map[int]bool // tabStops are the columns of the tab stops that are set or cleared
+	tabsCleared     bool         // tabsCleared is true when the default tab stops are cleared
+	defaultFG       Color
+	defaultBG       Color
+	amigaParser     bool
+	cupColRow       bool
+	autowrap        bool
+	bce             bool
+	debugAttrs      bool
+	contrastBoost   bool
+	monochrome      bool
+	invertAll       bool
+	autoWidth       bool
+	cssVars         bool
+	tailwind        bool
+	fixedGrid       bool
+	tooltips        bool
+	bareText        bool
+	quantize16      bool
+	quantize256     bool
+	boldMode        BoldMode
+	cubeSteps       [6]int // cubeSteps are the intensity steps of the Xterm color cube, or zero for the default
+	trailingNewline bool
+	aria            bool
+	ariaLabel       string
+	noWrap          bool // noWrap never wraps the text at the width, which is then only used to fill erased lines
+	minify          bool
+	upperHex        bool
+	trimTrailing    bool
+	font            string // font is the CSS font properties of the outer div
+	dir             string // dir is the text direction of the outer div
+	background      string // background is the CSS background-image property of the outer div
+	noAutowrap      bool
+	nulSpace        bool
+	controlPictures bool
+	wideFill        rune // wideFill is the character of the second column of a wide character, or 0 for empty
+	overstrike      bool
+	pageBreak       bool
+	petscii         bool
+	bell            func()
+	cellHook        func(Cell) Cell // cellHook transforms each cell before it is rendered, set by SetCellHook
+	strict          bool
+	br              *byteReader // br is the reader of the current Read, or nil when not reading
+	warnings        []error     // warnings are the skipped sequences that return errors in strict mode
+	stream          io.Writer   // stream is the writer of completed lines, or nil when fully buffered
+	streamed        int         // streamed is the number of lines written to the stream
+	streamOpen      bool        // streamOpen is true once the outer div is written to the stream
+	blankRun        int         // blankRun is the number of consecutive blank lines written to the stream
+	buffered        bool        // buffered is true once a stream falls back to full buffering
+	title           string
+	modes           map[int]bool // modes are the set or reset DEC private modes
 }
 
 // cell in the output buffer
 type cell struct {
-	Attr Attribute
-	Char rune
+	Attr  Attribute
+	Char  rune
+	marks string // marks are the zero-width combining characters that follow the character
+}
+
+// wideSpacer is the character of the cell that follows a wide character,
+// which keeps the columns of the grid aligned but is not rendered.
+const wideSpacer rune = -1
+
+// Cell is a single character cell of the decoded screen buffer.
+type Cell struct {
+	Attr Attribute // Attr is the styling of the character
+	Char string    // Char is the character, or the WideFill for the second column of a wide character
+}
+
+// Cells returns a copy of the decoded screen buffer as a grid of rows and cells,
+// which can be used to create a custom renderer.
+// As the Decoder reuses the memory of its lines after a [Decoder.Reset],
+// the copy remains valid after the Decoder is reset or reused.
+func (d *Decoder) Cells() [][]Cell {
+	rows := make([][]Cell, len(d.buffer))
+	for y, line := range d.buffer {
+		rows[y] = make([]Cell, len(line))
+		for x, c := range line {
+			rows[y][x] = Cell{Attr: c.Attr, Char: d.char(c)}
+		}
+	}
+	return rows
+}
+
+// ColorStats returns the number of cells that use each foreground and background color,
+// where the keys are the hex colors without a leading #, such as "a00".
+// The colors are those that are displayed, after the inverse and bold styles and the render options are applied,
+// and the default colors are included. The second column of a wide character is not counted.
+// This can help to decide whether an option such as Quantize16 or Tailwind is worthwhile.
+func (d *Decoder) ColorStats() (map[string]int, map[string]int) {
+	fgs, bgs := map[string]int{}, map[string]int{}
+	def := d.style(d.palette)
+	_, canvas := def.colors()
+	for _, line := range d.buffer {
+		for _, c := range line {
+			if c.Char == wideSpacer {
+				continue
+			}
+			fg, bg := effective(c.Attr, def)
+			if bg == "" {
+				bg = canvas
+			}
+			fgs[string(fg)]++
+			bgs[string(bg)]++
+		}
+	}
+	return fgs, bgs
+}
+
+// SetCellHook registers a function that is called on each cell before it is rendered to HTML,
+// by [Decoder.Lines], [Decoder.Write] and the other HTML methods, for example to redact text or force colors.
+// The hook receives a copy, so the screen buffer is unchanged. The second column of a wide character is not passed.
+// A nil function removes the hook.
+func (d *Decoder) SetCellHook(fn func(Cell) Cell) {
+	d.cellHook = fn
+}
+
+// hook returns a copy of the cells that are transformed by the cell hook,
+// or the unchanged cells when there is no hook.
+func (d *Decoder) hook(cells []cell) []cell {
+	if d.cellHook == nil {
+		return cells
+	}
+	out := make([]cell, len(cells))
+	for i, c := range cells {
+		if c.Char == wideSpacer {
+			out[i] = c
+			continue
+		}
+		h := d.cellHook(Cell{Attr: c.Attr, Char: string(c.Char) + c.marks})
+		r, size := utf8.DecodeRuneInString(h.Char)
+		if h.Char == "" {
+			r = ' '
+		}
+		out[i] = cell{Attr: h.Attr, Char: r, marks: h.Char[size:]}
+	}
+	return out
+}
+
+// Text returns the characters of the decoded screen buffer as plain text without any styling,
+// where each line is separated by a newline.
+// The second column of a wide character uses the WideFill of the [Customizer].
+func (d *Decoder) Text() string {
+	var b strings.Builder
+	for y, line := range d.buffer {
+		if y > 0 {
+			b.WriteByte('\n')
+		}
+		for _, c := range line {
+			b.WriteString(d.char(c))
+		}
+	}
+	return b.String()
+}
+
+// char returns the character and combining marks of the cell,
+// or the WideFill for the second column of a wide character.
+func (d *Decoder) char(c cell) string {
+	if c.Char == wideSpacer {
+		if d.wideFill == 0 {
+			return ""
+		}
+		return string(d.wideFill)
+	}
+	return string(c.Char) + c.marks
+}
+
+// CellChange is a cell of the decoded screen buffer that differs from a previous copy, returned by [Decoder.Diff].
+type CellChange struct {
+	Row     int  // Row is the line number of the cell, starting from 0
+	Col     int  // Col is the column number of the cell, starting from 0
+	Cell    Cell // Cell is the new cell, which is empty when the cell is removed
+	Removed bool // Removed is true when the cell is in the previous copy but no longer in the screen buffer
+}
+
+// Diff compares the decoded screen buffer to a previous copy returned by [Decoder.Cells],
+// and returns the cells that were changed, added or removed, in row and column order.
+// This lets a live view of the text only update the changed cells, instead of rewriting the HTML.
+func (d *Decoder) Diff(prev [][]Cell) []CellChange {
+	changes := []CellChange{}
+	cur := d.Cells()
+	for y := range max(len(cur), len(prev)) {
+		var now, was []Cell
+		if y < len(cur) {
+			now = cur[y]
+		}
+		if y < len(prev) {
+			was = prev[y]
+		}
+		for x := range max(len(now), len(was)) {
+			switch {
+			case x >= len(now):
+				changes = append(changes, CellChange{Row: y, Col: x, Removed: true})
+			case x >= len(was) || now[x] != was[x]:
+				changes = append(changes, CellChange{Row: y, Col: x, Cell: now[x]})
+			}
+		}
+	}
+	return changes
 }
 
 // Customizer is optional, and is used to configure the parsing of the ANSI encoded text.
@@ -216,6 +517,7 @@ type cell struct {
 type Customizer struct {
 	// Width is the number of columns of the ANSI encoded text.
 	// If a value provided is <= 0, then a common an 80 columns value is used.
+	// The [NoWrap] value never wraps the text, so the lines are as long as the content.
 	Width int
 	// The AmigaParser should be set to false except with edge cases where unusual
 	// Commodore Amiga specific encodings are to be parsed. When set to true:
@@ -223,6 +525,7 @@ type Customizer struct {
 	//   - 0x1B,0x5B,0x1B,0x5B "←[←[" is treated as a single ANSI escape control.
 	AmigaParser bool
 	// Strict is a debug mode that will throw errors when the ANSI includes malformed and invalid data or values.
+	// Otherwise the malformed and invalid sequences are skipped and listed by [Decoder.Warnings].
 	Strict bool
 	// Color Palette can either be CGA16, Xterm16, or DP2.
 	//   - CGA16 is the default, it is the Color Graphics Adapter colorset defined by IBM for the PC in 1981.
@@ -236,14 +539,155 @@ type Customizer struct {
 	// Modern artworks or terminal text will usually be in UTF-8 encoding
 	// which can be set with CharSet = nil or CharSet = [charmap.XUserDefined].
 	CharSet *charmap.Charmap
+	// PETSCII decodes the text using the Commodore 64 PETSCII character set and ignores the CharSet value,
+	// as the charmap package does not include it. The unshifted set of upper case letters and graphics is used,
+	// and the return character 0x0d moves the cursor to the start of the next line.
+	PETSCII bool
+	// Bell is an optional function that is called for each BEL bell character 0x07,
+	// for example to flash the screen when replaying the text. The bell is never written as a character.
+	Bell func()
+	// AutoCharset guesses the character encoding of the text using [DetectCharset]
+	// and ignores the CharSet value. It is only used by the [Customizer.Buffer] method,
+	// which then needs to read all the text before decoding it.
+	AutoCharset bool
+	// WideFill is the character held by the second column of a wide character, such as a CJK ideograph,
+	// in the grid returned by [Decoder.Cells], [Decoder.Text] and [Decoder.Diff].
+	// By default the column is empty, while a fill such as a space keeps one character per column.
+	// The fill is never rendered to HTML.
+	WideFill rune
+	// ControlPictures writes the unrecognized control characters below 0x20 and the DEL character 0x7f
+	// as the symbols of the Unicode Control Pictures block, for example ␃ for 0x03, instead of a space.
+	// This helps to debug the text, and is ignored when the CharSet is an IBM Code Page,
+	// as the controls are then characters.
+	ControlPictures bool
+	// NULSpace writes the NUL character 0x00 as a space, instead of ignoring it.
+	// Some fixed-width ANSI art uses NUL as a filler character that occupies a column.
+	NULSpace bool
+	// Overstrike interprets a backspace followed by a character as a typewriter style overstrike,
+	// which is commonly found in the output of man pages. When set to true:
+	//   - a character, backspace and the same character "x\bx" is written as a bold character.
+	//   - an underscore, backspace and a character "_\bx" is written as an underlined character.
+	Overstrike bool
+	// NoAutowrap disables the automatic wrap of text at the right margin, also known as DECAWM.
+	// When set to true, text written past the last column overwrites the last cell of the line.
+	// The ANSI can also toggle the automatic wrap using the ESC[?7h and ESC[?7l sequences.
+	NoAutowrap bool
+	// BackgroundColorErase fills the characters erased by the ANSI with the current background color,
+	// instead of the default background color. This is also known as BCE.
+	BackgroundColorErase bool
+	// DebugAttrs adds a data-sgr attribute to each HTML span element,
+	// containing the minimal SGR parameters of its active colors and styles, for example data-sgr="1;31;42",
+	// or data-sgr="0" for the default colors and styles.
+	DebugAttrs bool
+	// ContrastBoost lightens or darkens the foreground colors of text that does not meet
+	// the WCAG 2 AA minimum contrast ratio of 4.5:1 against its background, to help readers with low vision.
+	// For example, dark gray text on a black background is lightened. Concealed text is unchanged.
+	ContrastBoost bool
+	// Monochrome converts all colors to the shades of gray with the same luminance,
+	// which is useful for e-ink displays and printing. The bold, underline and inverse styles are kept.
+	Monochrome bool
+	// InvertAll swaps the foreground and background colors of every character and the outer div,
+	// independent of the SGR inverse attribute. For example, it shows light text on a dark background
+	// as dark text on a light background, to suit the theme of a website.
+	InvertAll bool
+	// AutoWidth measures the longest line of the text before it is decoded, and widens the Width
+	// when the line does not fit, so art with 132 or 160 columns does not wrap.
+	// The text is read in full before it is decoded.
+	AutoWidth bool
+	// CSSVars uses CSS custom properties instead of hexadecimal values for the 16 base colors of the palette
+	// and the default colors, for example color:var(--ansi-1) for red, so a stylesheet can change the theme.
+	// Other colors, such as those from the xterm 256 and RGB sequences, use hexadecimal values.
+	// The [CSSVarDefaults] function returns a stylesheet rule that declares the properties.
+	CSSVars bool
+	// Tailwind uses Tailwind CSS utility classes instead of inline styles, where the colors are arbitrary values,
+	// for example class="text-[#a00] bg-[#000] underline". No extra stylesheet is needed,
+	// but the classes must be available to the Tailwind build of the website.
+	Tailwind bool
+	// BoldMode sets whether bold text uses a lighter color, a bold font-weight, or both.
+	// The default BoldBright uses the lighter color, which matches the look of the IBM PC and older terminals.
+	BoldMode BoldMode
+	// Quantize16 replaces the true colors set by the SGR 38;2 and 48;2 parameters with the nearest color
+	// of the 16 colors of the Color Palette using [NearestColor], to suit a theme that only has those colors.
+	Quantize16 bool
+	// Quantize256 replaces the true colors set by the SGR 38;2 and 48;2 parameters with the nearest color
+	// of the Xterm 256 colors using [NearestXterm], which reduces the variety of colors in the output for caching.
+	// It is ignored when Quantize16 is set.
+	Quantize256 bool
+	// CubeSteps replaces the six intensity steps of the Xterm 256 color cube, codes 16 to 231,
+	// to match the colors of a target terminal. When all the values are 0, [XtermSteps] are used.
+	// The steps are used by the SGR 38;5 and 48;5 parameters and by Quantize256,
+	// while the [Xterm256] and [NearestXterm] functions always use the default steps.
+	// Values outside of 0 to 255 are clamped, or rejected by [Customizer.NewDecoderChecked].
+	CubeSteps [6]int
+	// Tooltips adds a title attribute to the spans of text with a foreground color other than the default,
+	// containing the source color as set by the ANSI before any bold or render options, for example title="#ff8700".
+	// Hovering over the text shows the color, which helps to inspect the colors of an artwork.
+	Tooltips bool
+	// FixedGrid sets the width of each span in ch units to the number of columns of its characters,
+	// using display:inline-block;width:Nch; so the columns stay aligned with fonts that have
+	// glyphs of varied widths, such as the fallback fonts for box-drawing characters.
+	FixedGrid bool
+	// BareText writes the runs of text that use the default colors and styles without a span element,
+	// as the outer div already sets the default colors, which gives a more compact HTML output.
+	// For example, <span style="color:#aaa;">text</span> is written as text.
+	BareText bool
+	// Minify shortens the HTML output, by shortening 6 digit hex colors to 3 digits where possible,
+	// dropping the final semicolon of the style attributes, and omitting the default colors.
+	Minify bool
+	// TrimTrailing removes the trailing spaces of each line that use the default colors and styles,
+	// which are often the result of cursor movements. Spaces with a background color are kept.
+	TrimTrailing bool
+	// UpperHex writes the hex color values of the HTML output in uppercase, such as "#FF0000",
+	// instead of the default lowercase "#ff0000". This includes the inline styles, the Tailwind classes,
+	// the Tooltips titles and the data-fg and data-bg attributes of the MaxSpansPerLine option.
+	UpperHex bool
+	// CUPColRow is a compatibility option for text created by buggy software, that sends the
+	// cursor position sequence with the column before the row, such as ESC[col;rowH.
+	// It should be set to false except for these edge cases, as the standard order is ESC[row;colH.
+	CUPColRow bool
+	// PageBreak changes the handling of the form feed control character 0x0C,
+	// which is only a control when the CharSet is not an IBM Code Page.
+	// When set to true, a form feed starts a new page below all existing lines and a blank line,
+	// otherwise, form feed is treated like the vertical tab control and moves the cursor down a line.
+	PageBreak bool
+	// MaxSpansPerLine is the maximum number of span elements of a line, which guards against
+	// text that changes the colors of every character and bloats the HTML with a span for each.
+	// A line that exceeds the maximum is written as a single span, where the colors of each column
+	// are listed in the data-fg and data-bg attributes, for example data-fg="a00 aaa a00" for three columns,
+	// which can be styled by a script. Other styles of the line are not kept.
+	// If a value provided is <= 0, then there is no maximum.
+	MaxSpansPerLine int
+	// MaxBlankRuns is the maximum number of consecutive blank lines that are rendered,
+	// where the excess blank lines of a longer run are dropped for a more compact HTML output.
+	// Lines that show a background color, such as those erased with the BackgroundColorErase option, are not blank.
+	// If a value provided is <= 0, then all the blank lines are kept.
+	MaxBlankRuns int
+	// TabWidth is the number of columns between each default tab stop used by the horizontal tab control.
+	// The ANSI can set a tab stop using the ESC H sequence, and clear the tab stops using the ESC[g and ESC[3g sequences.
+	// If a value provided is <= 0, then a common 8 columns value is used.
+	TabWidth int
+
+	fontFamily string // fontFamily is the CSS font-family of the outer wrapper, set by WithFont
+	fontSize   int    // fontSize is the CSS font-size in pixels of the outer wrapper, set by WithFont
+	dir        string // dir is the text direction of the outer wrapper, set by WithDirection
+	background string // background is the image URL of the outer wrapper, set by WithBackgroundImage
+
+	trailingNewline bool   // trailingNewline writes a newline after the outer wrapper, set by WithTrailingNewline
+	aria            bool   // aria describes the outer wrapper as an image for screen readers, set by WithARIA
+	ariaLabel       string // ariaLabel is the label of the image, set by WithARIA
 }
 
 // NewDecoder creates a Decoder with the given Customizer.
 func (c *Customizer) NewDecoder() *Decoder {
 	width := c.Width
+	noWrap := width == NoWrap
 	if width <= 0 {
 		width = 80
 	}
+	tabWidth := c.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = 8
+	}
 	charset := c.CharSet
 	if charset == nil {
 		charset = charmap.XUserDefined
@@ -251,32 +695,120 @@ func (c *Customizer) NewDecoder() *Decoder {
 	var def style
 	def.set(c.Color)
 	d := &Decoder{
-		charset:     charset,
-		palette:     c.Color,
-		buffer:      [][]cell{{}},
-		x:           0,
-		y:           0,
-		width:       width,
-		defaultFG:   def.fg,
-		defaultBG:   def.bg,
-		amigaParser: c.AmigaParser,
-		strict:      c.Strict,
+		charset:         charset,
+		palette:         c.Color,
+		buffer:          [][]cell{{}},
+		x:               0,
+		y:               0,
+		width:           width,
+		baseWidth:       width,
+		tabWidth:        tabWidth,
+		maxBlankRuns:    c.MaxBlankRuns,
+		maxSpans:        c.MaxSpansPerLine,
+		defaultFG:       def.fg,
+		defaultBG:       def.bg,
+		attr:            defaultAttr(c.Color),
+		amigaParser:     c.AmigaParser,
+		cupColRow:       c.CUPColRow,
+		autowrap:        !c.NoAutowrap,
+		bce:             c.BackgroundColorErase,
+		debugAttrs:      c.DebugAttrs,
+		contrastBoost:   c.ContrastBoost,
+		monochrome:      c.Monochrome,
+		invertAll:       c.InvertAll,
+		autoWidth:       c.AutoWidth,
+		cssVars:         c.CSSVars,
+		tailwind:        c.Tailwind,
+		fixedGrid:       c.FixedGrid,
+		tooltips:        c.Tooltips,
+		bareText:        c.BareText,
+		quantize16:      c.Quantize16,
+		quantize256:     c.Quantize256,
+		boldMode:        c.BoldMode,
+		cubeSteps:       c.CubeSteps,
+		noWrap:          noWrap,
+		trailingNewline: c.trailingNewline,
+		aria:            c.aria,
+		ariaLabel:       c.ariaLabel,
+		minify:          c.Minify,
+		upperHex:        c.UpperHex,
+		trimTrailing:    c.TrimTrailing,
+		font:            c.font(),
+		dir:             c.dir,
+		background:      c.backgroundImage(),
+		noAutowrap:      c.NoAutowrap,
+		nulSpace:        c.NULSpace,
+		controlPictures: c.ControlPictures,
+		wideFill:        c.WideFill,
+		overstrike:      c.Overstrike,
+		pageBreak:       c.PageBreak,
+		petscii:         c.PETSCII,
+		bell:            c.Bell,
+		strict:          c.Strict,
 	}
 	d.currentLine = d.buffer[0]
 	return d
 }
 
+// NewDecoderChecked creates a Decoder with the given Customizer, after validating its configuration.
+// The ErrUnknownPalette error is returned when the Color is not a known Palette,
+// and the ErrInvalidWidth error is returned for a negative Width other than [NoWrap].
+// The ErrCubeSteps error is returned when any of the CubeSteps are outside of 0 to 255.
+// Otherwise, [Customizer.NewDecoder] quietly uses empty colors or the default width.
+func (c *Customizer) NewDecoderChecked() (*Decoder, error) {
+	switch c.Color {
+	case CGA16, Xterm16, DP2:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownPalette, c.Color)
+	}
+	if c.Width < 0 && c.Width != NoWrap {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidWidth, c.Width)
+	}
+	const maxStep = 255
+	for _, step := range c.CubeSteps {
+		if step < 0 || step > maxStep {
+			return nil, fmt.Errorf("%w: %v", ErrCubeSteps, c.CubeSteps)
+		}
+	}
+	return c.NewDecoder(), nil
+}
+
+// Reset clears the screen buffer and cursor state of the Decoder so it can be reused to read new input.
+// The configuration of the Decoder, such as the width, palette, and charset, is kept,
+// and a width grown by the AutoWidth option is restored to the configured width.
+func (d *Decoder) Reset() {
+	putLines(d.buffer)
+	d.buffer = [][]cell{getLine()}
+	d.currentLine = d.buffer[0]
+	d.x, d.y = 0, 0
+	d.width = d.baseWidth
+	d.savedX, d.savedY = 0, 0
+	d.saved = nil
+	d.attr = defaultAttr(d.palette)
+	d.last = nil
+	d.wrapped = false
+	d.autowrap = !d.noAutowrap
+	d.streamed = 0
+	d.streamOpen = false
+	d.blankRun = 0
+	d.buffered = false
+	d.title = ""
+	d.modes = nil
+	d.warnings = nil
+	d.g0, d.g1 = 0, 0
+	d.shiftOut = false
+	d.cursorShape = 0
+	d.tabStops = nil
+	d.tabsCleared = false
+}
+
 // Buffer creates a new Buffer containing the HTML elements of the ANSI encoded text
 // found in the Reader.
 //
 // The parser configurations and arguments are configured using the [Customizer].
 func (c *Customizer) Buffer(r io.Reader) (*bytes.Buffer, error) {
-	if r == nil {
-		return nil, ErrReader
-	}
-
-	d := c.NewDecoder()
-	if err := d.Read(r); err != nil {
+	d, err := c.decode(r)
+	if err != nil {
 		return nil, err
 	}
 	var b bytes.Buffer
@@ -290,9 +822,52 @@ func (c *Customizer) Buffer(r io.Reader) (*bytes.Buffer, error) {
 	return &b, nil
 }
 
+// decode returns a new decoder that has read the ANSI encoded text found in the Reader,
+// which first detects the character set of the text when AutoCharset is set.
+func (c *Customizer) decode(r io.Reader) (*Decoder, error) {
+	if r == nil {
+		return nil, ErrReader
+	}
+	if c.AutoCharset {
+		p, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("buffer read all: %w", err)
+		}
+		cust := *c
+		cust.CharSet = DetectCharset(p)
+		cust.AutoCharset = false
+		return cust.decode(bytes.NewReader(p))
+	}
+	d := c.NewDecoder()
+	if err := d.Read(r); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// BufferAuto is the same as [Customizer.Buffer], except that a Reader of gzip compressed text,
+// found using the gzip magic number, is transparently decompressed before it is decoded.
+// Other text is decoded as is.
+func (c *Customizer) BufferAuto(r io.Reader) (*bytes.Buffer, error) {
+	if r == nil {
+		return nil, ErrReader
+	}
+	br := bufio.NewReader(r)
+	magic := []byte{0x1f, 0x8b}
+	if p, _ := br.Peek(len(magic)); !bytes.Equal(p, magic) {
+		return c.Buffer(br)
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("buffer auto gzip: %w", err)
+	}
+	defer zr.Close()
+	return c.Buffer(zr)
+}
+
 // Bytes returns the HTML elements of the ANSI encoded text found in the Reader.
 // It assumes the Reader is using IBM Code Page 437 encoding.
-// If width is <= 0, an 80 columns value is used.
+// If width is <= 0, an 80 columns value is used, except for [NoWrap] which never wraps the text.
 func Bytes(r io.Reader, width int) ([]byte, error) {
 	cust := Customizer{
 		Width:       width,
@@ -308,9 +883,28 @@ func Bytes(r io.Reader, width int) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// FromBytes returns a Decoder containing the decoded ANSI encoded text found in the byte slice,
+// which can then be written as HTML using [Decoder.Write].
+// It assumes the text is using IBM Code Page 437 encoding.
+// If width is <= 0, an 80 columns value is used, except for [NoWrap] which never wraps the text.
+func FromBytes(p []byte, width int) (*Decoder, error) {
+	cust := Customizer{
+		Width:       width,
+		AmigaParser: false,
+		Strict:      false,
+		Color:       CGA16,
+		CharSet:     charmap.CodePage437,
+	}
+	d := cust.NewDecoder()
+	if err := d.Read(bytes.NewReader(p)); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
 // String returns the HTML elements of the ANSI encoded text found in the Reader.
 // It assumes the Reader is using IBM Code Page 437 encoding.
-// If width is <= 0, an 80 columns value is used.
+// If width is <= 0, an 80 columns value is used, except for [NoWrap] which never wraps the text.
 func String(r io.Reader, width int) (string, error) {
 	cust := Customizer{
 		Width:       width,
@@ -328,7 +922,7 @@ func String(r io.Reader, width int) (string, error) {
 
 // WriteTo writes to w the HTML elements of the ANSI encoded text found in the Reader.
 // It assumes the Reader is using IBM Code Page 437 encoding.
-// If width is <= 0, an 80 columns value is used.
+// If width is <= 0, an 80 columns value is used, except for [NoWrap] which never wraps the text.
 //
 // The return int64 is the number of bytes written.
 func WriteTo(r io.Reader, w io.Writer, width int) (int64, error) {
@@ -355,166 +949,597 @@ func (d *Decoder) Write(w io.Writer) error {
 	if w == nil {
 		w = io.Discard
 	}
-	lines := d.Lines(d.palette)
-	// build default color values if possible: fallback to defaults in Decoder
-	defFg := d.defaultFG
-	defBg := d.defaultBG
-
-	// Write HTML directly without template overhead
-	if _, err := io.WriteString(w, `<div style="`); err != nil {
-		return fmt.Errorf("write opening div: %w", err)
+	if err := d.writeOpen(w); err != nil {
+		return err
+	}
+	if err := d.writeLines(w, "\n"); err != nil {
+		return err
 	}
-	if _, err := io.WriteString(w, defFg.FG()); err != nil {
-		return fmt.Errorf("write fg color: %w", err)
+	return d.writeClose(w)
+}
+
+// WriteMarkdownHTML writes to w the full HTML fragment as a single line that survives Markdown processing,
+// which is useful for Markdown documents that allow inline HTML.
+// The lines are joined with <br> elements instead of newlines,
+// and the outer div preserves the spaces of the text, which is needed as there is no pre element.
+func (d *Decoder) WriteMarkdownHTML(w io.Writer) error {
+	if w == nil {
+		w = io.Discard
 	}
-	if _, err := io.WriteString(w, defBg.BG()); err != nil {
-		return fmt.Errorf("write bg color: %w", err)
+	if err := d.writeOpen(w, "white-space:pre;"); err != nil {
+		return err
 	}
-	if _, err := io.WriteString(w, `">`); err != nil {
-		return fmt.Errorf("write div opening: %w", err)
+	if err := d.writeLines(w, "<br>"); err != nil {
+		return err
 	}
+	return d.writeClose(w)
+}
 
-	// Write lines directly without strings.Join allocation
-	for i, line := range lines {
+// writeLines writes the spans of each line directly without rendering the lines,
+// where the lines are separated by sep.
+func (d *Decoder) writeLines(w io.Writer, sep string) error {
+	defaults := d.style(d.palette)
+	i := 0
+	for cells := range d.visible(defaults) {
 		if i > 0 {
-			if _, err := io.WriteString(w, "\n"); err != nil {
-				return fmt.Errorf("write newline: %w", err)
+			if _, err := io.WriteString(w, sep); err != nil {
+				return fmt.Errorf("write line separator: %w", err)
+			}
+		}
+		if err := writeLine(w, cells, defaults); err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
+
+// visible returns an iterator of the buffer lines to render,
+// which drops the blank lines that exceed the MaxBlankRuns option.
+func (d *Decoder) visible(defaults style) iter.Seq[[]cell] {
+	return func(yield func([]cell) bool) {
+		run := 0
+		for _, cells := range d.buffer {
+			if d.excessBlank(cells, defaults, &run) {
+				continue
 			}
+			if !yield(d.hook(cells)) {
+				return
+			}
+		}
+	}
+}
+
+// excessBlank reports whether the line is a blank line that exceeds the MaxBlankRuns option,
+// where run is the number of consecutive blank lines, which is updated with the line.
+func (d *Decoder) excessBlank(cells []cell, defaults style, run *int) bool {
+	if d.maxBlankRuns <= 0 {
+		return false
+	}
+	if !blank(cells, defaults) {
+		*run = 0
+		return false
+	}
+	*run++
+	return *run > d.maxBlankRuns
+}
+
+// blank reports whether the line only contains spaces that show the default background color,
+// so a line filled with a background color is not blank.
+func blank(cells []cell, defaults style) bool {
+	_, canvas := defaults.colors()
+	for _, c := range cells {
+		if c.Char != ' ' || c.Attr.Underline || c.Attr.Double {
+			return false
+		}
+		if _, bg := effective(c.Attr, defaults); bg != "" && bg != canvas {
+			return false
+		}
+	}
+	return true
+}
+
+// Snapshot returns the full HTML fragment of the current screen buffer,
+// which is the same output as [Decoder.Write].
+// It can be called between successive Read calls to render each frame of an animation.
+func (d *Decoder) Snapshot() (string, error) {
+	var b strings.Builder
+	if err := d.Write(&b); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// DecodeLine decodes a single line of ANSI encoded text, such as a line of a log file read by a [bufio.Scanner],
+// and returns the HTML spans of the line without an outer div.
+// The line should not include a newline. A line that is longer than the width wraps,
+// and the rendered rows are then joined with newlines.
+//
+// The screen buffer and cursor are cleared before each line, but the current text attributes are kept,
+// so a color set by one line continues on the next.
+func (d *Decoder) DecodeLine(line []byte) (string, error) {
+	putLines(d.buffer)
+	d.buffer = [][]cell{getLine()}
+	d.currentLine = d.buffer[0]
+	d.x, d.y = 0, 0
+	if err := d.Read(bytes.NewReader(line)); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := d.writeLines(&b, "\n"); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// writeOpen writes the opening outer div element using the default colors
+// and any extra CSS properties.
+func (d *Decoder) writeOpen(w io.Writer, extra ...string) error {
+	def := d.style(d.palette)
+	defFg, defBg := def.colors()
+	props := []string{def.finish(def.colorCSS(defFg)), def.finish(def.backgroundCSS(defBg))}
+
+	open := `<div `
+	if d.dir != "" {
+		open += `dir="` + d.dir + `" `
+	}
+	if d.aria {
+		label := d.ariaLabel
+		if label == "" {
+			label = d.title
+		}
+		open += `role="img" aria-label="` + html.EscapeString(label) + `" `
+	}
+	if def.tailwind {
+		// the default colors are classes, while any other properties remain inline
+		open += `class="` + def.hexCase(tailwindColors(defFg, defBg)) + `" `
+		props = nil
+	}
+	css := joinCSS(d.minify, append(append(props, d.font, d.background), extra...)...)
+	if css != "" || !def.tailwind {
+		open += `style="` + css + `"`
+	}
+	open = strings.TrimSuffix(open, " ") + `>`
+
+	// Write HTML directly without template overhead
+	if _, err := io.WriteString(w, open); err != nil {
+		return fmt.Errorf("write opening div: %w", err)
+	}
+	return nil
+}
+
+// decorative reports whether the text only contains spaces and the box-drawing,
+// block element and geometric shape characters used to draw ANSI art.
+func decorative(text string) bool {
+	for _, r := range text {
+		if r != ' ' && (r < '\u2500' || r > '\u25ff') {
+			return false
 		}
-		if _, err := io.WriteString(w, line); err != nil {
-			return fmt.Errorf("write line: %w", err)
+	}
+	return true
+}
+
+// joinCSS joins the CSS properties of a style.
+// When minified, the properties are separated by semicolons without a final semicolon.
+func joinCSS(minified bool, props ...string) string {
+	if !minified {
+		return strings.Join(props, "")
+	}
+	parts := make([]string, 0, len(props))
+	for _, prop := range props {
+		if prop = strings.TrimSuffix(prop, ";"); prop != "" {
+			parts = append(parts, prop)
 		}
 	}
+	return strings.Join(parts, ";")
+}
 
-	if _, err := io.WriteString(w, `</div>`); err != nil {
+// writeClose writes the closing outer div element.
+func (d *Decoder) writeClose(w io.Writer) error {
+	closing := `</div>`
+	if d.trailingNewline {
+		closing += "\n"
+	}
+	if _, err := io.WriteString(w, closing); err != nil {
 		return fmt.Errorf("write closing div: %w", err)
 	}
 	return nil
 }
 
 // Lines renders each buffer line into a single HTML string.
-// Each contiguous run of identical attributes is wrapped in a <span style="...">.
+// Each contiguous run of identical styles is wrapped in a <span style="...">.
 func (d *Decoder) Lines(pal Palette) []string {
-	type span struct {
-		Attr Attribute
-		Text string
-	}
-	var defaults style
-	defaults.set(pal)
 	lines := []string{}
-	for _, cells := range d.buffer {
-		if len(cells) == 0 {
-			lines = append(lines, "")
-			continue
+	for line := range d.LinesSeq(pal) {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// LinesSeq returns an iterator that renders each buffer line into a single HTML string,
+// which avoids holding all the rendered lines in memory. The lines are the same as [Decoder.Lines].
+func (d *Decoder) LinesSeq(pal Palette) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		defaults := d.style(pal)
+		for cells := range d.visible(defaults) {
+			if !yield(renderLine(cells, defaults)) {
+				return
+			}
 		}
-		var lastAttr *Attribute
-		elems := make([]rune, 0, len(cells))
-		var spans []span
-		for _, cell := range cells {
-			if lastAttr == nil || !attrEqual(*lastAttr, cell.Attr) {
-				if len(elems) > 0 && lastAttr != nil {
-					var sb strings.Builder
-					for _, e := range elems {
-						sb.WriteRune(e)
-					}
-					spans = append(spans, span{Attr: *lastAttr, Text: sb.String()})
+	}
+}
+
+// renderLine renders the cells of a single line into a HTML string.
+func renderLine(cells []cell, defaults style) string {
+	var line strings.Builder
+	_ = writeLine(&line, cells, defaults) // strings.Builder never returns an error
+	return line.String()
+}
+
+// writeLine writes the HTML of the cells of a single line to w.
+// Each contiguous run of identical attributes is wrapped in a <span style="...">,
+// and adjacent runs that result in the same span element are merged into a single span.
+func writeLine(w io.Writer, cells []cell, defaults style) error {
+	if defaults.trim {
+		cells = trimTrailing(cells, defaultAttr(defaults.palette))
+	}
+	if len(cells) == 0 {
+		return nil
+	}
+	if defaults.maxSpans > 0 && spanCount(cells, defaults) > defaults.maxSpans {
+		return writeDataLine(w, cells, defaults)
+	}
+	var text strings.Builder
+	open := ""
+	cols := 0 // cols is the number of columns of the span
+	for i, c := range cells {
+		if i == 0 || !attrEqual(cells[i-1].Attr, c.Attr) {
+			next := spanOpen(c.Attr, defaults)
+			if i > 0 && next != open {
+				if err := writeRun(w, open, cols, text.String(), defaults); err != nil {
+					return err
 				}
-				tmp := cell.Attr
-				lastAttr = &tmp
-				elems = elems[:0]
+				text.Reset()
+				cols = 0
 			}
-			elems = append(elems, cell.Char)
+			open = next
 		}
-		if len(elems) > 0 && lastAttr != nil {
-			var sb strings.Builder
-			for _, e := range elems {
-				sb.WriteRune(e)
-			}
-			spans = append(spans, span{Attr: *lastAttr, Text: sb.String()})
+		cols++
+		if c.Char != wideSpacer {
+			text.WriteRune(c.Char)
+			text.WriteString(c.marks)
 		}
-		// Build HTML for line
-		var line strings.Builder
-		for _, sp := range spans {
-			style := buildStyle(sp.Attr, defaults)
-			line.WriteString(`<span style="`)
-			line.WriteString(html.EscapeString(style))
-			line.WriteString(`">`)
-			// escape text but preserve spaces
-			line.WriteString(html.EscapeString(sp.Text))
-			line.WriteString(`</span>`)
+	}
+	return writeRun(w, open, cols, text.String(), defaults)
+}
+
+// writeRun writes the HTML of a run of text with the opening span element and the number of columns.
+// With the BareText option, a run that uses the default style is written without a span element.
+func writeRun(w io.Writer, open string, cols int, text string, defaults style) error {
+	bare := defaults.bare && open == defaults.plain && !defaults.grid && (!defaults.aria || !decorative(text))
+	if bare {
+		if _, err := io.WriteString(w, html.EscapeString(text)); err != nil {
+			return fmt.Errorf("write text: %w", err)
 		}
-		lines = append(lines, line.String())
+		return nil
 	}
-	return lines
+	return writeSpan(w, gridOpen(open, cols, defaults), text, defaults.aria)
 }
 
-func pipeReplaceAll(r io.Reader, old, replacement []byte) io.Reader { //nolint:gocognit
-	pr, pw := io.Pipe()
-	const size = 32 * 1024
-	go func() {
-		defer pw.Close()
-		buf := make([]byte, size)
-		var carry []byte
-		for {
-			n, err := r.Read(buf)
-			if n > 0 { //nolint:nestif
-				data := carry
-				data = append(data, buf[:n]...)
-				// keep up to len(old)-1 bytes as carry for boundary matches
-				keep := 0
-				if len(old) > 1 {
-					keep = len(old) - 1
-				}
-				if len(data) > keep {
-					head := data[:len(data)-keep]
-					head = bytes.ReplaceAll(head, old, replacement)
-					if _, werr := pw.Write(head); werr != nil {
-						return
-					}
-					carry = append(carry[:0], data[len(data)-keep:]...)
-				} else {
-					carry = append(carry[:0], data...)
-				}
-			}
-			if err != nil {
-				if err == io.EOF {
-					if len(carry) > 0 {
-						out := bytes.ReplaceAll(carry, old, replacement)
-						_, _ = pw.Write(out)
-					}
-					return
-				}
-				_ = pw.CloseWithError(err)
-				return
+// spanCount returns the number of span elements used by writeLine for the cells.
+func spanCount(cells []cell, defaults style) int {
+	count, open := 0, ""
+	for i, c := range cells {
+		if i == 0 || !attrEqual(cells[i-1].Attr, c.Attr) {
+			if next := spanOpen(c.Attr, defaults); i == 0 || next != open {
+				count++
+				open = next
 			}
 		}
-	}()
-	return pr
+	}
+	return count
 }
 
-// Read reads bytes from r and interprets ANSI sequences, updating the buffer.
-func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
-	if d.amigaParser {
-		const cent, space = 0x9b, 0x20
-		// fixes for broken amiga ansis found in the wild.
-		// r = pipeReplaceAll(r, []byte{cent, space, byte('p')}, []byte{})
-		r = pipeReplaceAll(r, []byte{0x1b, byte('c'), 0x0c, cent}, []byte{})
-		r = pipeReplaceAll(r, []byte{byte('0'), space, byte('p'), 0x0a}, []byte{byte('\n')})
-		r = pipeReplaceAll(r, []byte{0x1b, 0x5b, 0x30, space, 0x70, 0x0c}, []byte{})
-		r = pipeReplaceAll(r, []byte{0x1b, 0x5b, 0x1b, 0x5b}, []byte{0x1b, 0x5b})
-		r = pipeReplaceAll(r, []byte{0x1b, 0x5b, 0x30, space}, []byte{space})
+// writeDataLine writes the HTML of the cells of a single line to w as a single span element,
+// where the foreground and background colors of each column are listed in the data-fg and data-bg attributes.
+// It is used when a line exceeds the MaxSpansPerLine option.
+func writeDataLine(w io.Writer, cells []cell, defaults style) error {
+	var text strings.Builder
+	fgs := make([]string, 0, len(cells))
+	bgs := make([]string, 0, len(cells))
+	_, canvas := defaults.colors()
+	for _, c := range cells {
+		fg, bg := effective(c.Attr, defaults)
+		if bg == "" {
+			bg = canvas
+		}
+		fgs = append(fgs, string(fg))
+		bgs = append(bgs, string(bg))
+		if c.Char != wideSpacer {
+			text.WriteRune(c.Char)
+			text.WriteString(c.marks)
+		}
+	}
+	fg, bg := strings.Join(fgs, " "), strings.Join(bgs, " ")
+	if defaults.upper {
+		fg, bg = strings.ToUpper(fg), strings.ToUpper(bg)
+	}
+	open := `<span data-fg="` + html.EscapeString(fg) + `" data-bg="` + html.EscapeString(bg) + `">`
+	return writeSpan(w, gridOpen(open, len(cells), defaults), text.String(), defaults.aria)
+}
+
+// gridOpen returns the opening HTML span element with the width of the columns in ch units
+// for the FixedGrid option, otherwise the element is returned unchanged.
+func gridOpen(open string, cols int, defaults style) string {
+	if !defaults.grid {
+		return open
+	}
+	n := strconv.Itoa(cols)
+	if defaults.tailwind {
+		return strings.Replace(open, ` class="`, ` class="inline-block w-[`+n+`ch] `, 1)
+	}
+	css := "display:inline-block;width:" + n + "ch;"
+	if strings.Contains(open, ` style="`) {
+		return strings.Replace(open, ` style="`, ` style="`+css, 1)
+	}
+	return strings.Replace(open, `<span`, `<span style="`+joinCSS(defaults.minify, css)+`"`, 1)
+}
+
+// trimTrailing returns the cells without any trailing spaces that use the default attribute.
+func trimTrailing(cells []cell, def Attribute) []cell {
+	end := len(cells)
+	for end > 0 && cells[end-1].Char == ' ' {
+		if !attrEqual(cells[end-1].Attr, def) {
+			break
+		}
+		end--
+	}
+	return cells[:end]
+}
+
+// spanOpen returns the opening HTML span element of the Attribute.
+func spanOpen(a Attribute, defaults style) string {
+	var b strings.Builder
+	b.WriteString(`<span`)
+	if defaults.tailwind {
+		b.WriteString(` class="` + html.EscapeString(tailwind(a, defaults)) + `"`)
+	} else if style := buildStyle(a, defaults); style != "" {
+		b.WriteString(` style="` + html.EscapeString(style) + `"`)
+	}
+	if title := tooltip(a, defaults); title != "" {
+		b.WriteString(` title="` + html.EscapeString(title) + `"`)
+	}
+	if defaults.debug {
+		b.WriteString(` data-sgr="` + debugSGR(a, defaults.palette) + `"`)
+	}
+	b.WriteString(`>`)
+	return b.String()
+}
+
+// tooltip returns the title of the span for the Tooltips option, which is the source foreground color
+// as a 6 digit hex value such as "#ff8700". An empty string is returned when the option is not used,
+// or the foreground is the default or an invalid color.
+func tooltip(a Attribute, defaults style) string {
+	if !defaults.tooltips || a.FG == "" || Color(a.FG) == defaults.fg || !Color(a.FG).Valid() {
+		return ""
+	}
+	v := Color(a.FG).rgba()
+	return defaults.hexCase(fmt.Sprintf("#%02x%02x%02x", v.R, v.G, v.B))
+}
+
+// writeSpan writes the HTML span element of the text using the opening span element.
+// When aria is true, a span of decorative text is hidden from screen readers.
+func writeSpan(w io.Writer, open, text string, aria bool) error {
+	if aria && decorative(text) {
+		open = `<span aria-hidden="true"` + strings.TrimPrefix(open, `<span`)
+	}
+	elems := [...]string{
+		open,
+		// escape text but preserve spaces
+		html.EscapeString(text), `</span>`,
+	}
+	for _, elem := range elems {
+		if _, err := io.WriteString(w, elem); err != nil {
+			return fmt.Errorf("write span: %w", err)
+		}
+	}
+	return nil
+}
+
+// NewStreamDecoder creates a Decoder with the given Customizer that writes
+// the HTML of completed lines to w as newlines are read,
+// so only the current line is kept in memory.
+//
+// When an upward cursor movement is encountered, the Decoder falls back to fully
+// buffering the remaining lines, as lines already written to w can no longer be changed.
+// [Decoder.Close] must be called once reading is complete to write the remaining lines.
+func (c *Customizer) NewStreamDecoder(w io.Writer) *Decoder {
+	if w == nil {
+		w = io.Discard
+	}
+	d := c.NewDecoder()
+	d.stream = w
+	return d
+}
+
+// flush writes the completed lines above the cursor to the stream writer.
+// It does nothing when the Decoder is not streaming or has fallen back to full buffering.
+func (d *Decoder) flush() error {
+	if d.stream == nil || d.buffered {
+		return nil
+	}
+	defaults := d.style(d.palette)
+	for ; d.streamed < d.y && d.streamed < len(d.buffer); d.streamed++ {
+		if err := d.streamLine(defaults); err != nil {
+			return err
+		}
+		// release the cells of the written line
+		d.buffer[d.streamed] = nil
+	}
+	return nil
+}
+
+// streamLine writes the line at the streamed index to the stream writer.
+func (d *Decoder) streamLine(defaults style) error {
+	if d.excessBlank(d.buffer[d.streamed], defaults, &d.blankRun) {
+		return nil
+	}
+	if !d.streamOpen {
+		if err := d.writeOpen(d.stream); err != nil {
+			return err
+		}
+		d.streamOpen = true
+	}
+	if d.streamed > 0 {
+		if _, err := io.WriteString(d.stream, "\n"); err != nil {
+			return fmt.Errorf("write newline: %w", err)
+		}
+	}
+	return writeLine(d.stream, d.hook(d.buffer[d.streamed]), defaults)
+}
+
+// Close writes any remaining lines and the closing div element to the writer
+// of a Decoder created by [Customizer.NewStreamDecoder].
+// It does nothing for other Decoders.
+func (d *Decoder) Close() error {
+	if d.stream == nil {
+		return nil
+	}
+	defaults := d.style(d.palette)
+	for ; d.streamed < len(d.buffer); d.streamed++ {
+		if err := d.streamLine(defaults); err != nil {
+			return err
+		}
+	}
+	if !d.streamOpen {
+		if err := d.writeOpen(d.stream); err != nil {
+			return err
+		}
+		d.streamOpen = true
+	}
+	return d.writeClose(d.stream)
+}
+
+func pipeReplaceAll(r io.Reader, old, replacement []byte) io.Reader { //nolint:gocognit
+	pr, pw := io.Pipe()
+	const size = 32 * 1024
+	go func() {
+		defer pw.Close()
+		buf := make([]byte, size)
+		var carry []byte
+		for {
+			n, err := r.Read(buf)
+			if n > 0 { //nolint:nestif
+				data := carry
+				data = append(data, buf[:n]...)
+				// keep up to len(old)-1 bytes as carry for boundary matches
+				keep := 0
+				if len(old) > 1 {
+					keep = len(old) - 1
+				}
+				if len(data) > keep {
+					head := data[:len(data)-keep]
+					head = bytes.ReplaceAll(head, old, replacement)
+					if _, werr := pw.Write(head); werr != nil {
+						return
+					}
+					carry = append(carry[:0], data[len(data)-keep:]...)
+				} else {
+					carry = append(carry[:0], data...)
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					if len(carry) > 0 {
+						out := bytes.ReplaceAll(carry, old, replacement)
+						_, _ = pw.Write(out)
+					}
+					return
+				}
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+	return pr
+}
+
+// Read reads bytes from r and interprets ANSI sequences, updating the buffer.
+//
+// Read can be called repeatedly on the same Decoder with successive chunks of a stream,
+// such as the frames of a terminal recording. The screen buffer, cursor position
+// and current text attributes are kept between calls, so the [Decoder.Snapshot], [Decoder.Lines] or [Decoder.Write]
+// methods can render the screen state after each chunk.
+func (d *Decoder) Read(r io.Reader) error {
+	return d.ReadContext(context.Background(), r)
+}
+
+// ReadAll reads the bytes of the readers in sequence as a single continuous stream,
+// such as a text split into multiple files, and interprets ANSI sequences, updating the buffer.
+// Unlike successive Read calls, a sequence or character can be split between the readers.
+// The ErrReader error is returned if any reader is nil.
+func (d *Decoder) ReadAll(readers ...io.Reader) error {
+	for _, r := range readers {
+		if r == nil {
+			return ErrReader
+		}
+	}
+	return d.Read(io.MultiReader(readers...))
+}
+
+// ReadContext reads bytes from r and interprets ANSI sequences, updating the buffer.
+// The context is checked periodically while reading, and if it is canceled,
+// the ctx.Err() is returned.
+func (d *Decoder) ReadContext(ctx context.Context, r io.Reader) error { //nolint:gocyclo,gocognit
+	if d.amigaParser {
+		const cent, space = 0x9b, 0x20
+		// fixes for broken amiga ansis found in the wild.
+		// r = pipeReplaceAll(r, []byte{cent, space, byte('p')}, []byte{})
+		r = pipeReplaceAll(r, []byte{0x1b, byte('c'), 0x0c, cent}, []byte{})
+		r = pipeReplaceAll(r, []byte{byte('0'), space, byte('p'), 0x0a}, []byte{byte('\n')})
+		r = pipeReplaceAll(r, []byte{0x1b, 0x5b, 0x30, space, 0x70, 0x0c}, []byte{})
+		r = pipeReplaceAll(r, []byte{0x1b, 0x5b, 0x1b, 0x5b}, []byte{0x1b, 0x5b})
+		r = pipeReplaceAll(r, []byte{0x1b, 0x5b, 0x30, space}, []byte{space})
 		r = pipeReplaceAll(r, []byte{0x1b, 0x5b, byte('3'), byte('4'), space},
 			[]byte{0x1b, 0x5b, byte('3'), byte('4'), byte('m'), space})
 	}
-	br := bufio.NewReader(r)
-	// current attribute applied to subsequent characters
-	cur := defaultAttr(d.palette)
 	// codepage is used to toggle the display of ASCII control codes as IBM PC characters.
 	// the bool result mentioning "code page" in the encoding.charMap name such as "IBM Code Page 437".
 	codepage := strings.Contains(strings.ToLower(d.charset.String()), "code page")
+	if d.autoWidth {
+		p, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("auto width read all: %w", err)
+		}
+		if n := d.measureWidth(p, codepage); n >= d.width {
+			// an extra column stops the longest line from wrapping the cursor to an empty line
+			d.width = n + 1
+		}
+		r = bytes.NewReader(p)
+	}
+	br := &byteReader{Reader: bufio.NewReader(r)}
+	d.br = br
+	defer func() { d.br = nil }()
+	if !codepage {
+		// skip a leading UTF-8 byte order mark, as it is not part of the text
+		bom := []byte{0xef, 0xbb, 0xbf}
+		if p, _ := br.Peek(len(bom)); bytes.Equal(p, bom) {
+			n, _ := br.Discard(len(bom))
+			br.offset += n
+		}
+	}
 	lineWrapping := false
 	const space = ' '
-	for {
+	// check the context for cancellation after this many bytes
+	const checkEvery = 1024
+	backspace := false
+	for n := 0; ; n++ {
+		if n%checkEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		br.seq = br.seq[:0]
 		b, err := br.ReadByte()
 		if err == io.EOF {
 			break
@@ -522,8 +1547,24 @@ func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
 		if err != nil {
 			return fmt.Errorf("play byte reader: %w", err)
 		}
+		struck := backspace
+		backspace = false
 		if b >= space {
-			d.writeChar(b, cur)
+			if struck {
+				d.writeChar(b, d.strike(b, d.attr))
+				continue
+			}
+			if b == DEL && d.controlPictures && !codepage {
+				d.writeRune(controlPicture(b), d.attr)
+				continue
+			}
+			if b >= utf8.RuneSelf && d.unicode() {
+				if r, ok := readRune(br, b); ok {
+					d.writeRune(r, d.attr)
+					continue
+				}
+			}
+			d.writeChar(b, d.attr)
 			continue
 		}
 		switch b {
@@ -531,8 +1572,56 @@ func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
 			if !lineWrapping {
 				d.newline()
 			}
+			if err := d.flush(); err != nil {
+				return err
+			}
+			continue
+		case NUL:
+			if d.nulSpace {
+				d.writeChar(space, d.attr)
+			}
+			continue
+		case '\r':
+			if d.petscii {
+				// the Commodore 64 return is both a carriage return and a line feed
+				d.newline()
+				if err := d.flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			if !lineWrapping {
+				d.setCursor(ptrInt(0), nil)
+			}
+			continue
+		case HT:
+			d.tab(d.attr)
+			continue
+		case BEL:
+			// the bell has no visual representation
+			if d.bell != nil {
+				d.bell()
+			}
+			continue
+		case VT, FF:
+			if b == FF && d.pageBreak {
+				d.formFeed()
+				continue
+			}
+			n := d.y + 1
+			d.setCursor(nil, &n)
 			continue
-		case '\r', NUL:
+		case SO, SI:
+			if codepage {
+				d.writeChar(b, d.attr)
+				continue
+			}
+			d.shiftOut = b == SO
+			continue
+		case BS:
+			n := d.x - 1
+			d.setCursor(&n, nil)
+			backspace = d.overstrike
 			continue
 		case EOF:
 			return nil
@@ -544,10 +1633,15 @@ func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
 			if err != nil {
 				return fmt.Errorf("play sequence reader: %w", err)
 			}
+			if nb == ']' {
+				if err := d.osc(br); err != nil {
+					return err
+				}
+				continue
+			}
 			if nb != '[' {
-				// We only handle CSI sequences (ESC [ ... )
-				if d.strict {
-					return fmt.Errorf("%w: %q", ErrUnknownEsc, nb)
+				if err := d.escape(br, nb); err != nil {
+					return err
 				}
 				continue
 			}
@@ -555,6 +1649,7 @@ func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
 			params := []int{}
 			paramInProgress, private, setmode, linewrp := false, false, false, false
 			paramVal := 0
+			var inter []byte
 			for {
 				cb, err := br.ReadByte()
 				if err == io.EOF {
@@ -586,16 +1681,16 @@ func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
 						continue
 					}
 					// ;; without number
-					if d.strict {
-						return ErrParam
-					}
 					params = append(params, -1)
 					continue
 				}
-				if cb == ' ' {
+				if 0x20 <= cb && cb <= 0x2f { //nolint:mnd
+					// intermediate bytes such as SP, !, " or $
+					inter = append(inter, cb)
 					continue
 				}
-				if cb == '?' {
+				if cb == '?' || cb == '>' {
+					// DEC private and secondary markers
 					private = true
 					continue
 				}
@@ -603,13 +1698,31 @@ func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
 				if paramInProgress {
 					params = append(params, paramVal)
 				}
+				if len(inter) > 0 {
+					handled, err := d.intermediate(inter, cb, params)
+					if err != nil {
+						return err
+					}
+					if handled {
+						break
+					}
+				}
 				sgrSequence := !private && cb == 'm' // SGR sequence: can be complex (including 38/48 extended)
+				if !sgrSequence && slices.Contains(params, -1) {
+					if err := d.fault(ErrParam); err != nil {
+						return err
+					}
+				}
 				if sgrSequence {
-					newAttr, err := ApplySGR(params, cur, d.palette)
+					newAttr, err := applySGR(implied(params), d.attr, d.palette, d.sgrOptions())
 					if err != nil {
 						return err
 					}
-					cur = newAttr
+					d.attr = newAttr
+				}
+				privateMode := private && (cb == 'h' || cb == 'l') // DEC private mode set or reset
+				if privateMode {
+					d.setMode(params, cb == 'h')
 				}
 				csiSequence := !private && cb != 'm' // other CSI sequences that affect cursor / buffer
 				if csiSequence {
@@ -621,19 +1734,409 @@ func (d *Decoder) Read(r io.Reader) error { //nolint:gocyclo,gocognit
 			}
 		default:
 			if codepage {
-				d.writeChar(b, cur)
+				d.writeChar(b, d.attr)
 				continue
 			}
 			// control codes like BEL, VT, etc. Ignore unless remap required.
-			if d.strict {
-				return fmt.Errorf("%w: 0x%02x", ErrUnknownCtr, b)
+			if err := d.fault(fmt.Errorf("%w: 0x%02x", ErrUnknownCtr, b)); err != nil {
+				return err
+			}
+			if d.controlPictures {
+				d.writeRune(controlPicture(b), d.attr)
+				continue
+			}
+			d.writeChar(byte(' '), d.attr)
+		}
+	}
+	return nil
+}
+
+// controlPicture returns the Unicode Control Pictures symbol of the control byte,
+// for example U+241B ␛ for the ESC control.
+func controlPicture(b byte) rune {
+	const pictures, del = 0x2400, 0x2421
+	if b == DEL {
+		return del
+	}
+	return pictures + rune(b)
+}
+
+// measureWidth returns the number of columns of the longest line of the text,
+// following the carriage returns, tabs, backspaces and the CSI sequences that move the cursor
+// horizontally, but without wrapping the lines. The other escape sequences, such as the OSC window title
+// and the SCS character sets, use no columns, while the control characters use the columns they are written with.
+// For unicode text, the characters are decoded and measured the same as they are written,
+// so wide characters use two columns and combining marks none.
+func (d *Decoder) measureWidth(p []byte, codepage bool) int { //nolint:cyclop,gocognit
+	longest, x := 0, 0
+	column := func(n int) {
+		x += n
+		longest = max(longest, x)
+	}
+	for i := 0; i < len(p); i++ {
+		b := p[i]
+		switch {
+		case b == '\n', b == '\r':
+			x = 0
+		case b == HT:
+			column((x/d.tabWidth+1)*d.tabWidth - x)
+		case b == BS:
+			x = max(0, x-1)
+		case b == ESC:
+			i, x = measureEscape(p, i, x, d.cupColRow)
+		case b == EOF:
+			return longest
+		case b == NUL:
+			if d.nulSpace {
+				column(1)
+			}
+		case b == BEL:
+			// the bell has no visual representation
+		case b == VT, b == FF:
+			if b == FF && d.pageBreak {
+				x = 0
+			}
+		case b == SO, b == SI:
+			if codepage {
+				column(1)
+			}
+		case b < ' ':
+			// the other controls are written as a character or a space
+			column(1)
+		case d.unicode() && b >= utf8.RuneSelf:
+			r, size := utf8.DecodeRune(p[i:])
+			if r == utf8.RuneError && size <= 1 {
+				// invalid bytes are written as the single characters of the byte
+				column(1)
+				continue
+			}
+			i += size - 1
+			column(runeWidth(r))
+		default:
+			column(1)
+		}
+	}
+	return longest
+}
+
+// measureEscape returns the index of the last byte of the escape sequence that starts at p[i],
+// and the cursor column x after the sequence. The colRow value is the CUPColRow option.
+func measureEscape(p []byte, i, x int, colRow bool) (int, int) {
+	if i+1 >= len(p) {
+		return i, x
+	}
+	switch p[i+1] {
+	case '[':
+		j := i + 2
+		for j < len(p) && (p[j] < '@' || p[j] > '~') {
+			j++
+		}
+		if j < len(p) {
+			x = measureCSI(x, p[j], string(p[i+2:j]), colRow)
+		}
+		return j, x
+	case ']':
+		// the OSC sequence ends with either the BEL or the ESC \ string terminator
+		for j := i + 2; j < len(p); j++ {
+			switch {
+			case p[j] == BEL:
+				return j, x
+			case p[j] == ESC && j+1 < len(p):
+				if p[j+1] == '\\' {
+					return j + 1, x
+				}
+				j++
+			}
+		}
+		return len(p), x
+	case '(', ')':
+		// SCS designates a character set with the following byte
+		return min(i+2, len(p)), x
+	case 'E', 'c':
+		// NEL and RIS move the cursor to the start of a line
+		return i + 1, 0
+	}
+	return i + 1, x
+}
+
+// measureCSI returns the cursor column x after the CSI sequence with the final byte and the params.
+// The colRow value is the CUPColRow option, where the column is the first parameter of the cursor position.
+func measureCSI(x int, final byte, params string, colRow bool) int {
+	fields := strings.Split(params, ";")
+	param := func(i int) int {
+		if i >= len(fields) {
+			return 1
+		}
+		n, err := strconv.Atoi(fields[i])
+		if err != nil || n < 1 {
+			return 1
+		}
+		return n
+	}
+	switch final {
+	case 'C', 'a':
+		return x + param(0)
+	case 'D':
+		return max(0, x-param(0))
+	case 'G':
+		return param(0) - 1
+	case 'H', 'f':
+		if colRow {
+			return param(0) - 1
+		}
+		return param(1) - 1
+	}
+	return x
+}
+
+// byteReader is a buffered reader that counts the bytes read
+// and keeps the bytes of the current character or sequence.
+type byteReader struct {
+	*bufio.Reader
+	offset int    // offset is the number of bytes read
+	seq    []byte // seq are the bytes read since the start of the current sequence
+}
+
+// ReadByte reads and returns a single byte.
+func (br *byteReader) ReadByte() (byte, error) {
+	b, err := br.Reader.ReadByte()
+	if err != nil {
+		return b, err //nolint:wrapcheck
+	}
+	br.offset++
+	br.seq = append(br.seq, b)
+	return b, nil
+}
+
+// fault returns the error as a [DecodeError] when the strict mode is used.
+// Otherwise the error and the sequence that caused it are kept as a warning and nil is returned.
+func (d *Decoder) fault(err error) error {
+	if d.br != nil {
+		seq := bytes.Clone(d.br.seq)
+		err = &DecodeError{Offset: d.br.offset - len(seq), Sequence: seq, Err: err}
+	}
+	if d.strict {
+		return err
+	}
+	d.warnings = append(d.warnings, err)
+	return nil
+}
+
+// Warnings returns the malformed and unrecognized sequences that were skipped by the Decoder,
+// which are the sequences that return an error in the strict mode.
+// Each warning contains the offset of the sequence in the Reader, the sequence bytes and the error,
+// for example: offset 4: "\x1b[1;2A": CUU A: expected 0 or 1 parameters: [1 2].
+func (d *Decoder) Warnings() []string {
+	s := make([]string, len(d.warnings))
+	for i, err := range d.warnings {
+		s[i] = err.Error()
+	}
+	return s
+}
+
+// Validate reports every malformed and unrecognized sequence of the ANSI encoded text found in the Reader,
+// without rendering any HTML. Unlike the strict mode of [Decoder.Read], it does not stop at the first error.
+// A nil result means the text is well-formed.
+// It assumes the Reader is using IBM Code Page 437 encoding.
+func Validate(r io.Reader) []DecodeError {
+	if r == nil {
+		return []DecodeError{{Err: ErrReader}}
+	}
+	cust := Customizer{CharSet: charmap.CodePage437}
+	d := cust.NewDecoder()
+	err := d.Read(r)
+	var errs []DecodeError
+	for _, w := range d.warnings {
+		var de *DecodeError
+		if errors.As(w, &de) {
+			errs = append(errs, *de)
+		}
+	}
+	if err != nil {
+		var de *DecodeError
+		if !errors.As(err, &de) {
+			de = &DecodeError{Err: err}
+		}
+		errs = append(errs, *de)
+	}
+	return errs
+}
+
+// escape handles the escape sequences, such as ESC M, that are not CSI sequences.
+// Unrecognized sequences are ignored unless the strict mode is used.
+func (d *Decoder) escape(br *byteReader, b byte) error {
+	switch b {
+	case '(', ')':
+		// SCS designates the G0 or G1 character set
+		set, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("play charset reader: %w", err)
+		}
+		return d.designate(b, set)
+	case 'D':
+		// IND index moves the cursor down one line
+		n := d.y + 1
+		d.setCursor(nil, &n)
+	case 'E':
+		// NEL next line moves the cursor to the start of the next line
+		d.newline()
+	case 'H':
+		// HTS sets a tab stop at the cursor column
+		if d.tabStops == nil {
+			d.tabStops = map[int]bool{}
+		}
+		d.tabStops[d.x] = true
+	case 'M':
+		d.reverseIndex()
+	case 'c':
+		return d.resetToInitial()
+	case '7':
+		// DECSC save cursor stores both the position and the attribute
+		d.saved = &saved{x: d.x, y: d.y, attr: d.attr}
+	case '8':
+		// DECRC restore cursor, without a save the cursor moves home and uses the default attribute
+		sc := saved{attr: defaultAttr(d.palette)}
+		if d.saved != nil {
+			sc = *d.saved
+		}
+		d.setCursor(&sc.x, &sc.y)
+		d.attr = sc.attr
+	default:
+		return d.fault(fmt.Errorf("%w: %q", ErrUnknownEsc, b))
+	}
+	return nil
+}
+
+// designate selects the G0 character set of the ESC ( sequence or the G1 character set of the ESC ) sequence,
+// which is either the DEC Special Graphics set 0 or the ASCII set B.
+func (d *Decoder) designate(g, set byte) error {
+	switch set {
+	case decGraphics, 'B':
+		if g == ')' {
+			d.g1 = set
+			return nil
+		}
+		d.g0 = set
+		return nil
+	}
+	return d.fault(fmt.Errorf("%w: %q", ErrUnknownEsc, string(g)+string(set)))
+}
+
+// resetToInitial clears the screen buffer, cursor and current attribute mid-stream, like [Decoder.Reset].
+// For a stream Decoder, the lines already written remain in the output and the new screen follows them.
+// Attr: RIS.
+func (d *Decoder) resetToInitial() error {
+	open := d.streamOpen
+	// the warnings of the text before the reset are kept, as are the read offsets of the reader,
+	// and the width that was measured for the whole text by the AutoWidth option
+	warnings, width := d.warnings, d.width
+	d.Reset()
+	d.warnings, d.width = warnings, width
+	if !open {
+		return nil
+	}
+	if _, err := io.WriteString(d.stream, "\n"); err != nil {
+		return fmt.Errorf("write newline: %w", err)
+	}
+	d.streamOpen = true
+	return nil
+}
+
+// reverseIndex moves the cursor up one line,
+// and when the cursor is at the top line, a blank line is inserted above it.
+// Attr: RI.
+func (d *Decoder) reverseIndex() {
+	if d.y > 0 {
+		n := d.y - 1
+		d.setCursor(nil, &n)
+		return
+	}
+	// lines already written to a stream cannot be shifted
+	d.buffered = true
+	d.buffer = slices.Insert(d.buffer, 0, []cell{})
+	d.ensureLine(0)
+}
+
+// osc reads an Operating System Command sequence (ESC ] ...) that is terminated
+// by either the BEL control character or the String Terminator (ESC \).
+// Only the commands to set the window title are kept, all others are ignored.
+func (d *Decoder) osc(br *byteReader) error {
+	var seq []byte
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			// truncated sequence
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("osc sequence reader: %w", err)
+		}
+		if b == BEL {
+			break
+		}
+		if b == ESC {
+			next, err := br.ReadByte()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("osc sequence reader: %w", err)
 			}
-			d.writeChar(byte(' '), cur)
+			if next == '\\' {
+				break
+			}
+			seq = append(seq, b, next)
+			continue
 		}
+		seq = append(seq, b)
+	}
+	cmd, text, found := bytes.Cut(seq, []byte{';'})
+	if !found {
+		return nil
+	}
+	// 0 sets the icon name and window title, 2 sets the window title
+	switch string(cmd) {
+	case "0", "2":
+		d.title = string(text)
 	}
 	return nil
 }
 
+// Title returns the last window title set by an Operating System Command sequence,
+// for example "ESC ] 0 ; title BEL".
+// An empty string is returned when no title has been set.
+func (d *Decoder) Title() string {
+	return d.title
+}
+
+// implied replaces the SGR parameters without a number with the implied 0 value,
+// for example the sequence ESC[1;;31m has the parameters 1, 0, 31.
+func implied(params []int) []int {
+	for i, p := range params {
+		if p < 0 {
+			params[i] = 0
+		}
+	}
+	return params
+}
+
+// debugSGR returns the minimal SGR parameters of the active state of the attribute for the DebugAttrs option,
+// such as "1;31;42", or "0" for the default attribute of the palette.
+func debugSGR(a Attribute, pal Palette) string {
+	params := a.PaletteSGR(pal)
+	if len(params) == 0 {
+		return "0"
+	}
+	s := make([]string, len(params))
+	for i, p := range params {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ";")
+}
+
 func resetCell(paramVal int, params []int) (bool, int, []int) {
 	params = append(params, paramVal)
 	return false, 0, params
@@ -679,10 +2182,7 @@ func (d *Decoder) CursorUp(params []int) error {
 		d.setCursor(nil, &n)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CUU A: %w: %d", ErrExpect0or1, params)
-	}
-	return nil
+	return d.fault(fmt.Errorf("CUU A: %w: %d", ErrExpect0or1, params))
 }
 
 // CursorDown moves cursor down.
@@ -698,9 +2198,21 @@ func (d *Decoder) CursorDown(params []int) error {
 		d.setCursor(nil, &n)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CUD B: %w: %d", ErrExpect0or1, params)
+	return d.fault(fmt.Errorf("CUD B: %w: %d", ErrExpect0or1, params))
+}
+
+// CursorVerticalRelative moves the cursor down a number of rows, which defaults to 1, and keeps the column.
+// Attr: VPR.
+func (d *Decoder) CursorVerticalRelative(params []int) error {
+	if len(params) > 1 {
+		return d.fault(fmt.Errorf("VPR e: %w: %d", ErrExpect0or1, params))
+	}
+	n := 1
+	if len(params) == 1 && params[0] > 0 {
+		n = params[0]
 	}
+	y := d.y + n
+	d.setCursor(nil, &y)
 	return nil
 }
 
@@ -717,9 +2229,22 @@ func (d *Decoder) CursorForward(params []int) error {
 		d.setCursor(&n, nil)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CUF C: %w: %d", ErrExpect0or1, params)
+	return d.fault(fmt.Errorf("CUF C: %w: %d", ErrExpect0or1, params))
+}
+
+// CursorHorizontalRelative moves the cursor forward a number of columns, which defaults to 1.
+// It currently moves the cursor like [Decoder.CursorForward].
+// Attr: HPR.
+func (d *Decoder) CursorHorizontalRelative(params []int) error {
+	if len(params) > 1 {
+		return d.fault(fmt.Errorf("HPR a: %w: %d", ErrExpect0or1, params))
 	}
+	n := 1
+	if len(params) == 1 && params[0] > 0 {
+		n = params[0]
+	}
+	x := d.x + n
+	d.setCursor(&x, nil)
 	return nil
 }
 
@@ -736,10 +2261,7 @@ func (d *Decoder) CursorBack(params []int) error {
 		d.setCursor(&n, nil)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CUB D: %w: %d", ErrExpect0or1, params)
-	}
-	return nil
+	return d.fault(fmt.Errorf("CUB D: %w: %d", ErrExpect0or1, params))
 }
 
 // CursorNextLine moves cursor down to the beginning of the line.
@@ -755,10 +2277,7 @@ func (d *Decoder) CursorNextLine(params []int) error {
 		d.setCursor(ptrInt(0), &n)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CNL E: %w: %d", ErrExpect0or1, params)
-	}
-	return nil
+	return d.fault(fmt.Errorf("CNL E: %w: %d", ErrExpect0or1, params))
 }
 
 // CursorPreviousLine moves cursor up to the beginning of the line.
@@ -774,10 +2293,7 @@ func (d *Decoder) CursorPreviousLine(params []int) error {
 		d.setCursor(ptrInt(0), &n)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CPL F: %w: %d", ErrExpect0or1, params)
-	}
-	return nil
+	return d.fault(fmt.Errorf("CPL F: %w: %d", ErrExpect0or1, params))
 }
 
 // CursorHorizontalAbsolute moves the cursor to column.
@@ -788,13 +2304,11 @@ func (d *Decoder) CursorHorizontalAbsolute(params []int) error {
 		d.setCursor(&n, nil)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CHA G: %w: %d", ErrExpect1, params)
-	}
-	return nil
+	return d.fault(fmt.Errorf("CHA G: %w: %d", ErrExpect1, params))
 }
 
-// CursorPosition moves the cursor to row and column.
+// CursorPosition moves the cursor to row and column,
+// or to column and row when the CUPColRow option is used.
 // Attr: CUP.
 func (d *Decoder) CursorPosition(params []int) error {
 	if len(params) == 0 {
@@ -807,13 +2321,15 @@ func (d *Decoder) CursorPosition(params []int) error {
 	if len(params) == pair {
 		x := params[1] - 1
 		y := params[0] - 1
+		if d.cupColRow {
+			x, y = y, x
+		}
 		d.setCursor(&x, &y)
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("CUP H/f: %w: %d", ErrExpect0or2, params)
+	if err := d.fault(fmt.Errorf("CUP H/f: %w: %d", ErrExpect0or2, params)); err != nil {
+		return err
 	}
-	// return nil
 	if len(params) == 1 {
 		y := params[0] - 1
 		x := 0
@@ -826,6 +2342,7 @@ func (d *Decoder) CursorPosition(params []int) error {
 // EraseInDisplay clears part of the screen.
 // Attr: ED.
 func (d *Decoder) EraseInDisplay(params []int) error {
+	bce := d.bceFill()
 	// 0 or empty: from cursor to end of screen
 	cursorToEOS := len(params) == 0 || (len(params) == 1 && params[0] == 0)
 	if cursorToEOS {
@@ -833,6 +2350,9 @@ func (d *Decoder) EraseInDisplay(params []int) error {
 		if d.x < len(d.currentLine) {
 			d.currentLine = d.currentLine[:d.x]
 		}
+		if bce {
+			d.currentLine = d.erase(d.currentLine, d.x, d.width)
+		}
 		// truncate lines below current
 		if d.y+1 < len(d.buffer) {
 			d.buffer = d.buffer[:d.y+1]
@@ -843,14 +2363,22 @@ func (d *Decoder) EraseInDisplay(params []int) error {
 	// erase up to cursor (from top to cursor)
 	fromTop := len(params) == 1 && params[0] == 1
 	if fromTop {
+		// blank the lines above the cursor but keep their lengths
 		for i := range d.y {
-			d.buffer[i] = []cell{}
+			end := len(d.buffer[i])
+			if bce {
+				end = d.width
+			}
+			d.buffer[i] = d.erase(d.buffer[i], 0, end)
 		}
-		if d.x < len(d.currentLine) {
+		switch {
+		case bce:
+			d.currentLine = d.erase(d.currentLine, 0, min(d.x+1, d.width))
+		case d.x < len(d.currentLine):
 			for i := 0; i <= d.x && i < len(d.currentLine); i++ {
 				d.currentLine[i] = cell{Attr: defaultAttr(d.palette), Char: ' '}
 			}
-		} else {
+		default:
 			d.currentLine = []cell{}
 		}
 		d.buffer[d.y] = d.currentLine
@@ -861,38 +2389,46 @@ func (d *Decoder) EraseInDisplay(params []int) error {
 	if entireScreen {
 		for i := range d.buffer {
 			d.buffer[i] = []cell{}
+			if bce {
+				d.buffer[i] = d.erase(d.buffer[i], 0, d.width)
+			}
 		}
-		d.currentLine = []cell{}
+		d.currentLine = d.buffer[0]
 		d.x = 0
 		d.y = 0
+		d.buffered = true
 		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("ED J: %w: %d", ErrRecognized, params)
-	}
-	return nil
+	return d.fault(fmt.Errorf("ED J: %w: %d", ErrRecognized, params))
 }
 
 // EraseInLine part of the line.
 // Attr: EL.
 func (d *Decoder) EraseInLine(params []int) error {
+	bce := d.bceFill()
 	// 0 or empty: from cursor to end of line
 	cursorToEOL := len(params) == 0 || (len(params) == 1 && params[0] == 0)
 	if cursorToEOL {
 		if d.x < len(d.currentLine) {
 			d.currentLine = d.currentLine[:d.x]
 		}
+		if bce {
+			d.currentLine = d.erase(d.currentLine, d.x, d.width)
+		}
 		d.buffer[d.y] = d.currentLine
 		return nil
 	}
 	// erase up to cursor in line
 	cursorInLine := len(params) == 1 && params[0] == 1
 	if cursorInLine {
-		if d.x < len(d.currentLine) {
+		switch {
+		case bce:
+			d.currentLine = d.erase(d.currentLine, 0, min(d.x+1, d.width))
+		case d.x < len(d.currentLine):
 			for i := 0; i <= d.x && i < len(d.currentLine); i++ {
 				d.currentLine[i] = cell{Attr: defaultAttr(d.palette), Char: ' '}
 			}
-		} else {
+		default:
 			d.currentLine = []cell{}
 		}
 		d.buffer[d.y] = d.currentLine
@@ -902,19 +2438,46 @@ func (d *Decoder) EraseInLine(params []int) error {
 	entireLine := len(params) == 1 && params[0] == 2 //nolint:mnd
 	if entireLine {
 		d.currentLine = []cell{}
+		if bce {
+			d.currentLine = d.erase(d.currentLine, 0, d.width)
+		}
 		d.buffer[d.y] = d.currentLine
+		return nil
 	}
-	if d.strict {
-		return fmt.Errorf("EL K: %w: %d", ErrRecognized, params)
+	return d.fault(fmt.Errorf("EL K: %w: %d", ErrRecognized, params))
+}
+
+// EraseCharacter erases characters from the cursor position without moving the cursor.
+// Attr: ECH.
+func (d *Decoder) EraseCharacter(params []int) error {
+	n := 1
+	switch {
+	case len(params) == 1:
+		n = max(1, params[0])
+	case len(params) > 1:
+		if err := d.fault(fmt.Errorf("ECH X: %w: %d", ErrExpect0or1, params)); err != nil {
+			return err
+		}
 	}
+	end := min(d.x+n, d.width)
+	if !d.bceFill() {
+		// cells past the end of the line are already blank
+		end = min(end, len(d.currentLine))
+	}
+	if d.x < end {
+		d.currentLine = d.erase(d.currentLine, d.x, end)
+	}
+	d.buffer[d.y] = d.currentLine
 	return nil
 }
 
 // SaveCursorPosition saves the cursor state for later use.
 // Abbr: RCP, SCORC.
 func (d *Decoder) SaveCursorPosition(params []int) error {
-	if len(params) != 0 && d.strict {
-		return fmt.Errorf("SCP s: %w: %d", ErrUnexpected, params)
+	if len(params) != 0 {
+		if err := d.fault(fmt.Errorf("SCP s: %w: %d", ErrUnexpected, params)); err != nil {
+			return err
+		}
 	}
 	d.savedX = d.x
 	d.savedY = d.y
@@ -924,16 +2487,165 @@ func (d *Decoder) SaveCursorPosition(params []int) error {
 // RestoreCursorPosition restores the saved cursor state.
 // Abbr: SCP, SCOSC.
 func (d *Decoder) RestoreCursorPosition(params []int) error {
-	if len(params) != 0 && d.strict {
-		return fmt.Errorf("RCP u: %w: %d", ErrUnexpected, params)
+	if len(params) != 0 {
+		if err := d.fault(fmt.Errorf("RCP u: %w: %d", ErrUnexpected, params)); err != nil {
+			return err
+		}
 	}
 	d.setCursor(&d.savedX, &d.savedY)
 	return nil
 }
 
+// decawm is the DEC private mode number of the autowrap mode.
+const decawm = 7
+
+// setMode sets or resets the DEC private modes of the params.
+// Only the autowrap mode (7) is used by the Decoder, all other modes are recorded but ignored.
+func (d *Decoder) setMode(params []int, set bool) {
+	for _, p := range params {
+		if p == decawm {
+			d.autowrap = set
+			continue
+		}
+		if d.modes == nil {
+			d.modes = make(map[int]bool)
+		}
+		d.modes[p] = set
+	}
+}
+
+// ModeEnabled reports whether the DEC private mode n is set,
+// for example ESC[?2004h sets the bracketed paste mode 2004.
+// Except for the autowrap mode 7, which is enabled by default, all modes are initially reset.
+func (d *Decoder) ModeEnabled(n int) bool {
+	if n == decawm {
+		return d.autowrap
+	}
+	return d.modes[n]
+}
+
+// RepeatChar repeats the last written character.
+// If no character has been written, then nothing happens.
+// Attr: REP.
+func (d *Decoder) RepeatChar(params []int) error {
+	n := 1
+	switch {
+	case len(params) == 1:
+		n = max(1, params[0])
+	case len(params) > 1:
+		if err := d.fault(fmt.Errorf("REP b: %w: %d", ErrExpect0or1, params)); err != nil {
+			return err
+		}
+	}
+	if d.last == nil {
+		return nil
+	}
+	last := *d.last
+	for range n {
+		d.writeRune(last.Char, last.Attr)
+		for _, mark := range last.marks {
+			d.writeRune(mark, last.Attr)
+		}
+	}
+	return nil
+}
+
+// TabClear clears the tab stop at the cursor column with the parameter 0,
+// or clears all the tab stops with the parameter 3. Without tab stops,
+// the horizontal tab control moves the cursor to the last column.
+// Attr: TBC.
+func (d *Decoder) TabClear(params []int) error {
+	const cursor, all = 0, 3
+	p := cursor
+	if len(params) > 0 {
+		p = params[0]
+	}
+	switch p {
+	case cursor:
+		if d.tabStops == nil {
+			d.tabStops = map[int]bool{}
+		}
+		d.tabStops[d.x] = false
+	case all:
+		d.tabStops = nil
+		d.tabsCleared = true
+	default:
+		return d.fault(fmt.Errorf("TBC g: %w: %v", ErrRecognized, params))
+	}
+	return nil
+}
+
+// DeviceStatusReport is a request for the terminal to report its status with the parameter 5,
+// or its cursor position with the parameter 6.
+// As there is no terminal to reply, both requests are consumed and ignored without changing the buffer.
+// Other parameters are unrecognized.
+// Attr: DSR.
+func (d *Decoder) DeviceStatusReport(params []int) error {
+	const status, cursor = 5, 6
+	if len(params) == 1 {
+		switch params[0] {
+		case status:
+			// the reply would be ESC [ 0 n for the terminal is ok
+			return nil
+		case cursor:
+			// the reply would be ESC [ row ; column R
+			return nil
+		}
+	}
+	return d.fault(fmt.Errorf("DSR n: %w: %v", ErrRecognized, params))
+}
+
+// DeviceAttributes is a request for the terminal to identify itself, including the
+// secondary ESC [ > c and tertiary ESC [ = c variants.
+// As there is no terminal to reply, the request is consumed and ignored.
+// Attr: DA.
+func (d *Decoder) DeviceAttributes(_ []int) error {
+	return nil
+}
+
+// CursorStyle is a request to change the shape of the terminal cursor, which is stored for [Decoder.CursorShape].
+// As the cursor is not rendered, the request does not change the output.
+// Attr: DECSCUSR.
+func (d *Decoder) CursorStyle(params []int) error {
+	const last = 6
+	shape := 0
+	if len(params) > 0 && params[0] > 0 {
+		shape = params[0]
+	}
+	if shape > last {
+		return d.fault(fmt.Errorf("%w: cursor shape %d", ErrRecognized, shape))
+	}
+	d.cursorShape = shape
+	return nil
+}
+
+// CursorShape returns the shape of the cursor requested by the last ESC [ n SP q sequence,
+// where 0 and 1 are a blinking block, 2 is a steady block, 3 is a blinking underline,
+// 4 is a steady underline, 5 is a blinking bar and 6 is a steady bar.
+// The value is 0 when no shape has been requested.
+func (d *Decoder) CursorShape() int {
+	return d.cursorShape
+}
+
+// intermediate handles the CSI sequences that use intermediate bytes before the final byte,
+// such as ESC [ 1 SP q. Unrecognized sequences are ignored unless the strict mode is used.
+//
+// The handled result is false when the intermediates are only spaces and the sequence is not recognized,
+// as spaces are found in the wild as padding, so the sequence is then parsed as if they were not there.
+func (d *Decoder) intermediate(inter []byte, final byte, params []int) (bool, error) {
+	switch string(inter) + string(final) {
+	case " q":
+		return true, d.CursorStyle(params)
+	}
+	if len(bytes.Trim(inter, " ")) == 0 {
+		return false, nil
+	}
+	return true, d.fault(fmt.Errorf("%w: %s%c", ErrUnknownCSI, inter, final))
+}
+
 // ApplyCSI handles cursor movement and erase sequences that alter the buffer or cursor.
 // It follows standard ANSI/VT100 CSI final bytes used in the original request:
-// A B C D E F G H f J K s u
+// A B C D E F G H f J K X a b c e g n s u
 func (d *Decoder) ApplyCSI(final byte, params []int) error {
 	switch final {
 	case 'A':
@@ -956,16 +2668,57 @@ func (d *Decoder) ApplyCSI(final byte, params []int) error {
 		return d.EraseInDisplay(params)
 	case 'K':
 		return d.EraseInLine(params)
+	case 'X':
+		return d.EraseCharacter(params)
+	case 'a':
+		return d.CursorHorizontalRelative(params)
+	case 'b':
+		return d.RepeatChar(params)
+	case 'c':
+		return d.DeviceAttributes(params)
+	case 'e':
+		return d.CursorVerticalRelative(params)
+	case 'g':
+		return d.TabClear(params)
+	case 'n':
+		return d.DeviceStatusReport(params)
 	case 's':
 		return d.SaveCursorPosition(params)
 	case 'u':
 		return d.RestoreCursorPosition(params)
 	default:
-		if d.strict {
-			return fmt.Errorf("%w: %c", ErrUnknownCSI, final)
-		}
+		return d.fault(fmt.Errorf("%w: %c", ErrUnknownCSI, final))
 	}
-	return nil
+}
+
+// SupportedCSI returns the CSI final bytes that are handled by [Decoder.ApplyCSI].
+// The SGR final byte 'm' and the DEC private modes are handled by [Decoder.Read].
+func SupportedCSI() []byte {
+	return []byte("ABCDEFGHfJKXabcegnsu")
+}
+
+// SupportedSGR returns the SGR parameters that are recognized by [ApplySGR], in numeric order.
+// The parameters 38 and 48 are the extended colors, which use the 5;n and 2;r;g;b values.
+func SupportedSGR() []int {
+	codes := []int{
+		Reset, Bold, Faint, Underline, Invert, Conceal,
+		DoubleUnderline, NotBoldFaint, NotUnderline, NotInvert, NotConceal,
+	}
+	for code := FG1st; code <= FGEnd; code++ {
+		codes = append(codes, code)
+	}
+	codes = append(codes, SetFG, DefaultFG)
+	for code := BG1st; code <= BGEnd; code++ {
+		codes = append(codes, code)
+	}
+	codes = append(codes, SetBG, DefaultBG)
+	for code := BrightFG1st; code <= BrightFGEnd; code++ {
+		codes = append(codes, code)
+	}
+	for code := BrightBG1st; code <= BrightBGEnd; code++ {
+		codes = append(codes, code)
+	}
+	return codes
 }
 
 // defaultAttr returns the default Attribute (no formatting and default foreground color).
@@ -974,11 +2727,25 @@ func defaultAttr(pal Palette) Attribute {
 	s.set(pal)
 	fg := s.fg
 	bg := s.bg
-	return Attribute{FG: string(fg), BG: string(bg), Bold: false, Underline: false, Inverse: false}
+	return Attribute{
+		FG: string(fg), BG: string(bg),
+		Bold: false, Faint: false, Underline: false, Double: false, Inverse: false, Conceal: false,
+	}
 }
 
 // ApplySGR applies SGR parameters to an incoming attribute and returns a new Attribute.
-func ApplySGR(params []int, cur Attribute, pal Palette) (Attribute, error) { //nolint:gocognit
+func ApplySGR(params []int, cur Attribute, pal Palette) (Attribute, error) {
+	return applySGR(params, cur, pal, sgrOptions{})
+}
+
+// sgrOptions are the Decoder options used by applySGR.
+type sgrOptions struct {
+	quantize func(string) string // quantize optionally replaces the hex colors of the true color parameters
+	cube     *[6]int             // cube optionally replaces the intensity steps of the Xterm color cube
+}
+
+// applySGR applies SGR parameters to an incoming attribute and returns a new Attribute.
+func applySGR(params []int, cur Attribute, pal Palette, opts sgrOptions) (Attribute, error) { //nolint:gocognit
 	attr := cur // start from current
 	if len(params) == 0 {
 		// treat empty SGR as reset per common implementations
@@ -998,16 +2765,26 @@ func ApplySGR(params []int, cur Attribute, pal Palette) (Attribute, error) { //n
 			attr = defaultAttr(pal)
 		case p == Bold:
 			attr.Bold = true
-		case p == NotBold || p == NotBoldFaint:
+		case p == Faint:
+			attr.Faint = true
+		case p == NotBoldFaint:
 			attr.Bold = false
+			attr.Faint = false
 		case p == Underline:
 			attr.Underline = true
+		case p == DoubleUnderline:
+			attr.Double = true
 		case p == NotUnderline:
 			attr.Underline = false
+			attr.Double = false
 		case p == Invert:
 			attr.Inverse = true
 		case p == NotInvert:
 			attr.Inverse = false
+		case p == Conceal:
+			attr.Conceal = true
+		case p == NotConceal:
+			attr.Conceal = false
 		case p == DefaultFG:
 			attr.FG = ""
 		case p == DefaultBG:
@@ -1039,10 +2816,14 @@ func ApplySGR(params []int, cur Attribute, pal Palette) (Attribute, error) { //n
 					continue
 				}
 				code := params[i+vals]
+				hex := XtermHex(code, pal)
+				if opts.cube != nil {
+					hex = cubeHex(code, *opts.cube, pal)
+				}
 				if isFG {
-					attr.FG = XtermHex(code, pal)
+					attr.FG = hex
 				} else {
-					attr.BG = XtermHex(code, pal)
+					attr.BG = hex
 				}
 				i += 3
 				continue
@@ -1053,10 +2834,14 @@ func ApplySGR(params []int, cur Attribute, pal Palette) (Attribute, error) { //n
 					i++
 					continue
 				}
+				hex := RGBHex(params, i)
+				if opts.quantize != nil {
+					hex = opts.quantize(hex)
+				}
 				if isFG {
-					attr.FG = RGBHex(params, i)
+					attr.FG = hex
 				} else {
-					attr.BG = RGBHex(params, i)
+					attr.BG = hex
 				}
 				i += 5
 				continue
@@ -1073,6 +2858,109 @@ func ApplySGR(params []int, cur Attribute, pal Palette) (Attribute, error) { //n
 	return attr, nil
 }
 
+// ParseSGR parses a string of SGR parameters separated by semicolons, such as "1;31;42",
+// and applies them to the current attribute to return a new Attribute.
+// Empty parameters are treated as 0, while a non-numeric parameter returns an error.
+func ParseSGR(s string, cur Attribute, pal Palette) (Attribute, error) {
+	if s == "" {
+		return ApplySGR(nil, cur, pal)
+	}
+	fields := strings.Split(s, ";")
+	params := make([]int, 0, len(fields))
+	for _, field := range fields {
+		if field == "" {
+			params = append(params, 0)
+			continue
+		}
+		p, err := strconv.Atoi(field)
+		if err != nil || p < 0 {
+			return cur, fmt.Errorf("sgr %q: %w: %q", s, ErrRecognized, field)
+		}
+		params = append(params, p)
+	}
+	return ApplySGR(params, cur, pal)
+}
+
+// SGR returns the SGR parameters that reproduce the attribute when applied to an empty Attribute,
+// for example a bold, red foreground on a green background returns [1, 31, 42].
+// As the attribute does not record its palette, a color is matched against the palettes in the order
+// CGA16, Xterm16 and then DP2, and the first match uses the named codes.
+// The other Xterm 256 colors use the 5;n codes, and the remaining colors use the 2;r;g;b true color codes.
+// An empty foreground or background color is the default and so is not included,
+// while the explicit default colors of a decoded cell are included, such as [37, 40] for CGA16.
+// Use [Attribute.PaletteSGR] for the minimal parameters of a decoded cell.
+func (a Attribute) SGR() []int {
+	return a.sgrParams([]Colors{CGA(), Xterm(), DPaint2()}, "", "")
+}
+
+// PaletteSGR returns the minimal SGR parameters that reproduce the attribute when applied
+// to the default Attribute of the palette, which is the attribute of a new or reset Decoder.
+// Only the colors of the palette use the named codes, and the default foreground and background colors
+// of the palette are not included. For example, a bold, red foreground on the default black background
+// of CGA16 returns [1, 31].
+func (a Attribute) PaletteSGR(pal Palette) []int {
+	def := defaultAttr(pal)
+	return a.sgrParams([]Colors{pal.colors()}, def.FG, def.BG)
+}
+
+// sgrParams returns the SGR parameters of the attribute, where the colors are matched against the palettes
+// in order and the default foreground and background colors are not included.
+func (a Attribute) sgrParams(palettes []Colors, defFG, defBG string) []int {
+	params := []int{}
+	flags := []struct {
+		set  bool
+		code int
+	}{
+		{a.Bold, Bold}, {a.Faint, Faint}, {a.Underline, Underline}, {a.Double, DoubleUnderline},
+		{a.Inverse, Invert}, {a.Conceal, Conceal},
+	}
+	for _, f := range flags {
+		if f.set {
+			params = append(params, f.code)
+		}
+	}
+	if a.FG != defFG {
+		params = append(params, colorSGR(a.FG, FG1st, BrightFG1st, SetFG, palettes)...)
+	}
+	if a.BG != defBG {
+		params = append(params, colorSGR(a.BG, BG1st, BrightBG1st, SetBG, palettes)...)
+	}
+	return params
+}
+
+// colorSGR returns the SGR parameters of the hex color using the first code of the normal and bright colors
+// of the first matching palette, or the extended color code. An empty or invalid color returns nil.
+func colorSGR(hex string, first, bright, extended int, palettes []Colors) []int {
+	if hex == "" {
+		return nil
+	}
+	for _, colors := range palettes {
+		for i, c := range colors {
+			if string(c) != hex {
+				continue
+			}
+			const normal = 8
+			if i < normal {
+				return []int{first + i}
+			}
+			return []int{bright + i - normal}
+		}
+	}
+	const xterm256c, truecolor = 5, 2
+	table := xtermTable()
+	for code := 16; code < len(table); code++ {
+		if string(table[code]) == hex {
+			return []int{extended, xterm256c, code}
+		}
+	}
+	c := Color(hex)
+	if !c.Valid() {
+		return nil
+	}
+	rgb := c.rgba()
+	return []int{extended, truecolor, int(rgb.R), int(rgb.G), int(rgb.B)}
+}
+
 // RGBHex converts the params into a "true color", red, green, blue hex string.
 func RGBHex(params []int, i int) string {
 	if len(params) < i+4 {
@@ -1127,7 +3015,6 @@ func BasicHex(code int, bright bool, pal Palette) string {
 //
 //nolint:mnd
 func XtermHex(code int, pal Palette) string {
-	const hex = "%02x%02x%02x"
 	if code < 0 || code > 255 {
 		return ""
 	}
@@ -1138,13 +3025,36 @@ func XtermHex(code int, pal Palette) string {
 		c := code - 8
 		return BasicHex(c, true, pal)
 	}
-	if code <= 255 {
-		r, g, b := XtermColors(code)
-		return fmt.Sprintf(hex, r, g, b)
+	return string(xtermTable()[code])
+}
+
+// Xterm256 returns all the 256 Xterm colors as hexadecimal values.
+// The system colors, codes 0 to 15, use the colors of the Palette,
+// while the color cube and the grayscale colors, codes 16 to 255, are the same for every Palette
+// and use the default [XtermSteps].
+//
+//nolint:mnd
+func Xterm256(pal Palette) [256]Color {
+	table := *xtermTable()
+	for code := range 16 {
+		table[code] = Color(BasicHex(code%8, code >= 8, pal))
 	}
-	return ""
+	return table
 }
 
+// xtermTable returns the precomputed Xterm colors.
+// The system colors, codes 0 to 15, are left blank.
+// The table is shared to avoid copying it on each lookup, so it must not be modified.
+var xtermTable = sync.OnceValue(func() *[256]Color { //nolint:gochecknoglobals
+	const hex = "%02x%02x%02x"
+	var table [256]Color
+	for code := 16; code < len(table); code++ {
+		r, g, b := XtermColors(code)
+		table[code] = Color(fmt.Sprintf(hex, r, g, b))
+	}
+	return &table
+})
+
 // XtermColors takes a Xterm non-system color code and returns the corresponding RGB values.
 // The code values begin at 16 and finish at 255.
 // If a code is out of range, then the returned RGB values will be -1, which are invalid.
@@ -1168,20 +3078,44 @@ func XtermColors(code int) (int, int, int) {
 //
 //nolint:mnd
 func XtermColor(code int) (int, int, int) {
+	return XtermCube(code, XtermSteps())
+}
+
+// XtermSteps returns the intensity steps of the red, green and blue values of the Xterm color cube,
+// where the step values are 0 and then 55 + n*40.
+//
+//nolint:mnd
+func XtermSteps() [6]int {
+	return [6]int{0, 95, 135, 175, 215, 255}
+}
+
+// XtermCube returns the RGB values for the Xterm color cube codes 16 to 231,
+// using the six intensity steps of the red, green and blue values.
+// For example, the web safe steps {0, 51, 102, 153, 204, 255} match the cube of some older terminals.
+// If a code is out of range, then the returned RGB values will be -1, which are invalid.
+//
+//nolint:mnd
+func XtermCube(code int, steps [6]int) (int, int, int) {
+	if code < 16 || code > 231 {
+		return -1, -1, -1
+	}
 	c := code - 16
 	r := c / 36
 	g := (c % 36) / 6
 	b := c % 6
-	calc := func(c int) int {
-		if c == 0 {
-			return 0
-		}
-		return 55 + c*40
+	return steps[r], steps[g], steps[b]
+}
+
+// cubeHex returns the hex value of the Xterm color code like [XtermHex],
+// except that the color cube uses the intensity steps, which are clamped to 0 to 255.
+//
+//nolint:mnd
+func cubeHex(code int, steps [6]int, pal Palette) string {
+	if code < 16 || code > 231 {
+		return XtermHex(code, pal)
 	}
-	r = calc(r)
-	g = calc(g)
-	b = calc(b)
-	return r, g, b
+	r, g, b := XtermCube(code, steps)
+	return fmt.Sprintf("%02x%02x%02x", clamp(r, 0, 255), clamp(g, 0, 255), clamp(b, 0, 255))
 }
 
 // XtermGray returns the RGB values for the Xterm greyscale colors.
@@ -1194,14 +3128,59 @@ func XtermGray(code int) (int, int, int) {
 }
 
 func attrEqual(a, b Attribute) bool {
-	return a.FG == b.FG && a.BG == b.BG && a.Bold == b.Bold && a.Underline == b.Underline && a.Inverse == b.Inverse
+	return a.FG == b.FG && a.BG == b.BG && a.Bold == b.Bold && a.Faint == b.Faint &&
+		a.Underline == b.Underline && a.Double == b.Double && a.Inverse == b.Inverse && a.Conceal == b.Conceal
 }
 
-// style contains the default Colors and palette
+// style contains the default Colors and palette, and the options used to render the HTML
 type style struct {
-	palette Palette
-	fg      Color
-	bg      Color
+	palette  Palette
+	fg       Color
+	bg       Color
+	minify   bool
+	upper    bool
+	trim     bool
+	debug    bool
+	contrast bool
+	mono     bool
+	invert   bool
+	aria     bool
+	vars     bool
+	tailwind bool
+	grid     bool
+	tooltips bool
+	maxSpans int
+	bare     bool   // bare writes the text of the default style without a span element
+	plain    string // plain is the opening span element of the default attribute, used by bare
+	bold     BoldMode
+}
+
+// style returns the default colors of the palette and the HTML render options of the Decoder.
+func (d *Decoder) style(pal Palette) style {
+	var s style
+	s.set(pal)
+	s.minify = d.minify
+	s.upper = d.upperHex
+	s.trim = d.trimTrailing
+	s.debug = d.debugAttrs
+	s.contrast = d.contrastBoost
+	s.invert = d.invertAll
+	s.aria = d.aria
+	s.vars = d.cssVars
+	s.tailwind = d.tailwind
+	s.bold = d.boldMode
+	s.grid = d.fixedGrid
+	s.tooltips = d.tooltips
+	s.maxSpans = d.maxSpans
+	if d.monochrome {
+		s.mono = true
+		s.fg, s.bg = Gray(s.fg), Gray(s.bg)
+	}
+	if d.bareText {
+		s.bare = true
+		s.plain = spanOpen(defaultAttr(pal), s)
+	}
+	return s
 }
 
 // set the default colors of the palette
@@ -1220,42 +3199,470 @@ func (s *style) set(pal Palette) {
 	}
 }
 
+// tailwind takes the Attribute and returns the Tailwind CSS utility classes,
+// which use arbitrary values for the colors, for example "text-[#a00] bg-[#000] font-bold underline".
+// The font-bold class is only used when the BoldMode uses the font-weight.
+func tailwind(a Attribute, def style) string {
+	fg, bg := effective(a, def)
+	classes := []string{}
+	if s := tailwindColors(fg, bg); s != "" {
+		classes = append(classes, s)
+	}
+	if a.Bold && def.weight() {
+		classes = append(classes, "font-bold")
+	}
+	switch {
+	case a.Double:
+		classes = append(classes, "underline decoration-double")
+	case a.Underline:
+		classes = append(classes, "underline")
+	}
+	return def.hexCase(strings.Join(classes, " "))
+}
+
+// tailwindColors returns the Tailwind CSS text and background color classes of the valid colors.
+func tailwindColors(fg, bg Color) string {
+	classes := []string{}
+	if fg.Valid() {
+		classes = append(classes, "text-[#"+string(fg)+"]")
+	}
+	if bg.Valid() {
+		classes = append(classes, "bg-[#"+string(bg)+"]")
+	}
+	return strings.Join(classes, " ")
+}
+
+// colorCSS returns the CSS color property of the color,
+// which uses a custom property in the CSSVars mode.
+func (def style) colorCSS(c Color) string {
+	if v := def.cssVar(c); v != "" {
+		return "color:" + v + ";"
+	}
+	return c.FG()
+}
+
+// backgroundCSS returns the CSS background-color property of the color,
+// which uses a custom property in the CSSVars mode.
+func (def style) backgroundCSS(c Color) string {
+	if v := def.cssVar(c); v != "" {
+		return "background-color:" + v + ";"
+	}
+	return c.BG()
+}
+
+// cssVar returns the CSS custom property of the color in the CSSVars mode,
+// such as var(--ansi-1) for the red of the palette or var(--ansi-fg) for the default foreground.
+// An empty string is returned for the other colors or when not in the CSSVars mode.
+func (def style) cssVar(c Color) string {
+	if !def.vars || c == "" {
+		return ""
+	}
+	switch c {
+	case def.fg:
+		return "var(--ansi-fg)"
+	case def.bg:
+		return "var(--ansi-bg)"
+	}
+	const bright = 8
+	for code := range 16 {
+		if Color(BasicHex(code%bright, code >= bright, def.palette)) == c {
+			return "var(--ansi-" + strconv.Itoa(code) + ")"
+		}
+	}
+	return ""
+}
+
+// colors returns the default foreground and background colors of the outer div container,
+// which are swapped when the whole output is inverted.
+func (def style) colors() (Color, Color) {
+	if def.invert {
+		return def.bg, def.fg
+	}
+	return def.fg, def.bg
+}
+
 // buildStyle takes the Attribute and returns a HTML style attribute.
 func buildStyle(a Attribute, def style) string {
+	fg, bg := effective(a, def)
+	defFg, defBg := def.colors()
+	parts := []string{}
+	// the default foreground color is redundant when minified,
+	// as this will be handled by a parent div container.
+	if fg != "" && (!def.minify || fg != defFg) {
+		parts = append(parts, def.colorCSS(fg))
+	}
+	// Don't provide a default background color when bg is empty,
+	// as this will be handled by a parent div container.
+	if bg != "" && (!def.minify || bg != defBg) {
+		const black = CBlack
+		if def.invert || bg.BG() != black.BG() {
+			parts = append(parts, def.backgroundCSS(bg))
+		}
+	}
+	if a.Bold && def.weight() {
+		parts = append(parts, "font-weight:bold;")
+	}
+	switch {
+	case a.Double:
+		parts = append(parts, "text-decoration:underline;text-decoration-style:double;")
+	case a.Underline:
+		parts = append(parts, "text-decoration:underline;")
+	}
+	return def.finish(strings.Join(parts, ""))
+}
+
+// weight reports whether the BoldMode uses the bold font-weight.
+func (def style) weight() bool {
+	return def.bold == BoldWeight || def.bold == BoldBoth
+}
+
+// finish applies the render options to the CSS properties of the style and sanitizes the result.
+func (def style) finish(css string) string {
+	if def.minify {
+		css = minify(css)
+	}
+	return sanitize(def.hexCase(css))
+}
+
+// hexCase returns the text with the hex color values in uppercase for the UpperHex option,
+// otherwise the text is returned unchanged.
+func (def style) hexCase(text string) string {
+	if def.upper {
+		return upperHex(text)
+	}
+	return text
+}
+
+// upperHex returns the style with the hex color values in uppercase, such as "#ff0000" to "#FF0000".
+func upperHex(style string) string {
+	b := []byte(style)
+	hex := false
+	for i, c := range b {
+		switch {
+		case c == '#':
+			hex = true
+		case !hex:
+		case 'a' <= c && c <= 'f':
+			b[i] = c - ('a' - 'A')
+		case '0' <= c && c <= '9':
+		default:
+			hex = false
+		}
+	}
+	return string(b)
+}
+
+// sanitize removes all the characters from the style that are not expected in the CSS properties,
+// to prevent the injection of other properties or HTML attributes.
+func sanitize(style string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z', '0' <= r && r <= '9', 'A' <= r && r <= 'F':
+			return r
+		case r == '#', r == ':', r == ';', r == '-', r == '(', r == ')':
+			return r
+		}
+		return -1
+	}, style)
+}
+
+// minify shortens the CSS properties of the style,
+// by shortening 6 digit hex colors to 3 digits where possible and dropping the final semicolon.
+func minify(style string) string {
+	var b strings.Builder
+	for i := 0; i < len(style); i++ {
+		b.WriteByte(style[i])
+		if style[i] != '#' {
+			continue
+		}
+		hex := style[i+1:]
+		if end := strings.IndexByte(hex, ';'); end >= 0 {
+			hex = hex[:end]
+		}
+		if short := shortHex(hex); short != hex {
+			b.WriteString(short)
+			i += len(hex)
+		}
+	}
+	return strings.TrimSuffix(b.String(), ";")
+}
+
+// shortHex returns the 3 digit version of a 6 digit hex color, such as "aabbcc" to "abc".
+// If the hex color cannot be shortened without loss, then it is returned unchanged.
+func shortHex(hex string) string {
+	const long = 6
+	if len(hex) != long || hex[0] != hex[1] || hex[2] != hex[3] || hex[4] != hex[5] {
+		return hex
+	}
+	return string([]byte{hex[0], hex[2], hex[4]})
+}
+
+// effective takes the Attribute and returns the foreground and background colors
+// to display, after the inverse and bold styles are applied.
+// An empty background color is the default background color.
+func effective(a Attribute, def style) (Color, Color) {
 	// Determine effective fg/bg respecting inverse
 	fg := a.FG // foreground color
 	bg := a.BG // background color
 	if a.Inverse {
+		// substitute the default colors, otherwise the swap can display the default foreground on itself
+		if fg == "" {
+			fg = string(def.fg)
+		}
+		if bg == "" {
+			bg = string(def.bg)
+		}
 		fg, bg = bg, fg
 	}
-	parts := []string{}
 	var val Color
 	switch {
-	case a.Bold && fg != "":
-		val = Bright(Color(fg), def.palette)
-	case a.Bold && fg == "":
-		val = Bright(def.fg, def.palette)
+	case a.Bold && def.bold != BoldWeight && fg != "":
+		val = bold(Color(fg), def.palette)
+	case a.Bold && def.bold != BoldWeight && fg == "":
+		val = bold(def.fg, def.palette)
 	case fg != "":
 		val = Color(fg)
 	case fg == "":
 		val = def.fg
 	}
-	if val != "" {
-		parts = append(parts, val.FG())
+	if a.Faint {
+		val = Dim(val)
 	}
-	// Don't provide a default background color when bg is empty,
-	// as this will be handled by a parent div container.
-	if bg != "" {
-		val := Color(bg)
-		const black = CBlack
-		if val != "" && val.BG() != black.BG() {
-			parts = append(parts, val.BG())
+	if a.Conceal {
+		// the text remains in the document and can be copied
+		val = Color(bg)
+		if bg == "" {
+			val = def.bg
 		}
 	}
-	if a.Underline {
-		parts = append(parts, "text-decoration:underline;")
+	if def.mono {
+		val = Gray(val)
+		if bg != "" {
+			bg = string(Gray(Color(bg)))
+		}
+	}
+	if def.contrast && !a.Conceal {
+		back := Color(bg)
+		if back == "" {
+			back = def.bg
+		}
+		val = boost(val, back)
+	}
+	if def.invert {
+		back := Color(bg)
+		if back == "" {
+			back = def.bg
+		}
+		return back, val
+	}
+	return val, Color(bg)
+}
+
+// minContrast is the WCAG 2 AA minimum contrast ratio for normal text.
+const minContrast = 4.5
+
+// luminance returns the WCAG 2 relative luminance of the color, from 0 for black to 1 for white.
+func (c Color) luminance() float64 {
+	v := c.rgba()
+	linear := func(u uint8) float64 {
+		s := float64(u) / 0xff
+		if s <= 0.04045 { //nolint:mnd
+			return s / 12.92 //nolint:mnd
+		}
+		return math.Pow((s+0.055)/1.055, 2.4) //nolint:mnd
+	}
+	return 0.2126*linear(v.R) + 0.7152*linear(v.G) + 0.0722*linear(v.B) //nolint:mnd
+}
+
+// contrast returns the WCAG 2 contrast ratio of the two colors, from 1 to 21.
+func contrast(a, b Color) float64 {
+	la, lb := a.luminance(), b.luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05) //nolint:mnd
+}
+
+// boost returns the foreground color adjusted to meet the minimum contrast ratio against the background.
+// The color is gradually mixed with either white or black, whichever contrasts more with the background.
+// Colors that already meet the ratio and invalid colors are returned unchanged.
+func boost(fg, bg Color) Color {
+	if !fg.Valid() || !bg.Valid() || contrast(fg, bg) >= minContrast {
+		return fg
+	}
+	target := Color("fff")
+	if contrast("000", bg) > contrast(target, bg) {
+		target = "000"
+	}
+	from, to := fg.rgba(), target.rgba()
+	mix := func(a, b uint8, k float64) uint8 {
+		return uint8(math.Round(float64(a) + (float64(b)-float64(a))*k))
+	}
+	const steps = 20
+	val := fg
+	for i := 1; i <= steps; i++ {
+		k := float64(i) / steps
+		val = Color(fmt.Sprintf("%02x%02x%02x", mix(from.R, to.R, k), mix(from.G, to.G, k), mix(from.B, to.B, k)))
+		if contrast(val, bg) >= minContrast {
+			break
+		}
+	}
+	return val
+}
+
+// Gray takes a color and returns the shade of gray with the same relative luminance.
+// For example, Color.CRed "a00" returns "535353" and Color.CGray "aaa" returns "aaa".
+// Invalid colors return a blank color.
+func Gray(c Color) Color {
+	if !c.Valid() {
+		return ""
+	}
+	l := c.luminance()
+	// convert the linear luminance back to the sRGB gamma
+	s := 12.92 * l     //nolint:mnd
+	if l > 0.0031308 { //nolint:mnd
+		s = 1.055*math.Pow(l, 1/2.4) - 0.055 //nolint:mnd
 	}
-	return strings.Join(parts, "")
+	v := uint8(math.Round(s * 0xff))
+	if v%0x11 == 0 {
+		// use the short form for the shades shared with the palettes, such as "aaa"
+		return Color(fmt.Sprintf("%x%x%x", v/0x11, v/0x11, v/0x11))
+	}
+	return Color(fmt.Sprintf("%02x%02x%02x", v, v, v))
+}
+
+// NearestColor takes a hex color and returns the color of the palette with the shortest
+// Euclidean distance between their red, green and blue values.
+// For example, "fe0102" returns Color.CRed "a00" of the CGA16 palette.
+// The hex color may have a leading "#", and invalid colors return a blank color.
+func NearestColor(hex string, pal Palette) Color {
+	c := Color(strings.TrimPrefix(hex, "#"))
+	if !c.Valid() {
+		return ""
+	}
+	v := c.rgba()
+	nearest, best := Color(""), math.MaxInt
+	const colors = 16
+	for code := range colors {
+		p := Color(BasicHex(code%8, code >= 8, pal)) //nolint:mnd
+		if dist := distance(v, p.rgba()); dist < best {
+			nearest, best = p, dist
+		}
+	}
+	return nearest
+}
+
+// distance returns the squared Euclidean distance between the red, green and blue values of the colors.
+func distance(a, b color.RGBA) int {
+	r := int(a.R) - int(b.R)
+	g := int(a.G) - int(b.G)
+	bl := int(a.B) - int(b.B)
+	return r*r + g*g + bl*bl
+}
+
+// NearestXterm takes a hex color and returns the Xterm color code with the shortest
+// Euclidean distance between their red, green and blue values.
+// Only the color cube and the grayscale colors, codes 16 to 255, are used,
+// as the system colors depend on the Palette. For example, "7f7f7f" returns the gray 244.
+// The hex color may have a leading "#", and invalid colors return -1.
+// The color cube uses the default [XtermSteps].
+func NearestXterm(hex string) int {
+	return nearestXterm(hex, XtermSteps())
+}
+
+// nearestXterm returns the nearest Xterm color code of the hex color like [NearestXterm],
+// using the intensity steps of the color cube.
+func nearestXterm(hex string, steps [6]int) int {
+	c := Color(strings.TrimPrefix(hex, "#"))
+	if !c.Valid() {
+		return -1
+	}
+	v := c.rgba()
+	nearest, best := -1, math.MaxInt
+	const first, cube, last = 16, 231, 255
+	for code := first; code <= last; code++ {
+		r, g, b := XtermColors(code)
+		if code <= cube {
+			r, g, b = XtermCube(code, steps)
+		}
+		r, g, b = clamp(r, 0, last), clamp(g, 0, last), clamp(b, 0, last)
+		x := color.RGBA{R: uint8(r), G: uint8(g), B: uint8(b), A: 0xff} //nolint:gosec
+		if dist := distance(v, x); dist < best {
+			nearest, best = code, dist
+		}
+	}
+	return nearest
+}
+
+// sgrOptions returns the options of the Decoder used to apply the SGR parameters.
+func (d *Decoder) sgrOptions() sgrOptions {
+	opts := sgrOptions{quantize: d.quantize(), cube: nil}
+	if d.cubeSteps != [6]int{} {
+		opts.cube = &d.cubeSteps
+	}
+	return opts
+}
+
+// quantize returns the function that replaces the true colors set by the SGR parameters,
+// or nil to keep the true colors.
+func (d *Decoder) quantize() func(string) string {
+	if d.quantize16 {
+		return func(hex string) string {
+			return string(NearestColor(hex, d.palette))
+		}
+	}
+	if d.quantize256 {
+		steps := XtermSteps()
+		if d.cubeSteps != [6]int{} {
+			steps = d.cubeSteps
+		}
+		return func(hex string) string {
+			return cubeHex(nearestXterm(hex, steps), steps, d.palette)
+		}
+	}
+	return nil
+}
+
+// Dim takes a color and returns a darker variant at half the intensity.
+// For example, Color.CWhite "fff" returns "7f7f7f".
+// Invalid colors return a blank color.
+func Dim(c Color) Color {
+	if !c.Valid() {
+		return ""
+	}
+	v := c.rgba()
+	return Color(fmt.Sprintf("%02x%02x%02x", v.R/2, v.G/2, v.B/2)) //nolint:mnd
+}
+
+// Lighten takes a color and returns a lighter variant, a third closer to white.
+// For example, "ff8700" returns "ffaf55".
+// Invalid colors return a blank color.
+func Lighten(c Color) Color {
+	if !c.Valid() {
+		return ""
+	}
+	v := c.rgba()
+	const white, third = 0xff, 3
+	up := func(x uint8) uint8 {
+		return x + (white-x)/third
+	}
+	return Color(fmt.Sprintf("%02x%02x%02x", up(v.R), up(v.G), up(v.B)))
+}
+
+// bold returns the color of bold text, which is the lighter variant of the palette using [Bright].
+// The bright colors of the palette are unchanged, while other colors, such as those from the
+// xterm 256 and RGB sequences, are lightened using [Lighten].
+func bold(c Color, pal Palette) Color {
+	if b := Bright(c, pal); b != "" {
+		return b
+	}
+	const colors = 8
+	for code := range colors {
+		if string(c) == BasicHex(code, true, pal) {
+			return c
+		}
+	}
+	return Lighten(c)
 }
 
 // Bright takes a palette color and swaps it for a lighter variant.
@@ -1287,49 +3694,314 @@ func Bright(c Color, pal Palette) Color {
 
 // setCursor sets x and/or y (nil means unchanged)
 func (d *Decoder) setCursor(xp *int, yp *int) {
+	if yp != nil && *yp < d.y {
+		// lines already written to a stream cannot be revisited
+		d.buffered = true
+	}
 	if xp != nil {
 		d.x = max(0, *xp)
 	}
 	if yp != nil {
 		d.y = max(0, *yp)
 	}
+	d.wrapped = false
 	d.ensureLine(d.y)
 }
 
 // ensureLine ensures the current line exists
 func (d *Decoder) ensureLine(y int) {
 	for y >= len(d.buffer) {
-		d.buffer = append(d.buffer, []cell{})
+		d.buffer = append(d.buffer, getLine())
 	}
 	d.currentLine = d.buffer[y]
 }
 
+// linePool reuses the cells of the lines of a Decoder after it is Reset,
+// which reduces the allocations when decoding many texts.
+var linePool = sync.Pool{ //nolint:gochecknoglobals
+	New: func() any {
+		const columns = 80
+		line := make([]cell, 0, columns)
+		return &line
+	},
+}
+
+// getLine returns an empty line from the pool.
+func getLine() []cell {
+	line, _ := linePool.Get().(*[]cell)
+	if line == nil {
+		return []cell{}
+	}
+	return (*line)[:0]
+}
+
+// putLines returns the lines to the pool.
+// The lines must not be used after they are returned.
+func putLines(lines [][]cell) {
+	for _, line := range lines {
+		if cap(line) == 0 {
+			continue
+		}
+		line = line[:0]
+		linePool.Put(&line)
+	}
+}
+
 // newline moves cursor to start of next line
 func (d *Decoder) newline() {
 	d.setCursor(ptrInt(0), ptrInt(d.y+1))
 }
 
+// unicode reports whether the text is decoded as UTF-8,
+// which is when the CharSet is nil or [charmap.XUserDefined].
+func (d *Decoder) unicode() bool {
+	return (d.charset == nil || d.charset == charmap.XUserDefined) && !d.petscii
+}
+
+// readRune returns the UTF-8 encoded character that starts with the byte b,
+// reading the continuation bytes from br.
+// Only the number of continuation bytes given by the lead byte are peeked,
+// so a live reader is never blocked waiting for bytes that follow the character.
+// The ok result is false when the bytes are not valid UTF-8, and then nothing is read.
+func readRune(br *byteReader, b byte) (rune, bool) {
+	var n int
+	switch {
+	case b >= 0xc0 && b < 0xe0:
+		n = 1
+	case b >= 0xe0 && b < 0xf0:
+		n = 2
+	case b >= 0xf0 && b < 0xf8:
+		n = 3
+	default:
+		// an ASCII, continuation or invalid byte
+		return 0, false
+	}
+	p, _ := br.Peek(n)
+	r, size := utf8.DecodeRune(append([]byte{b}, p...))
+	if r == utf8.RuneError && size <= 1 {
+		return 0, false
+	}
+	for range size - 1 {
+		_, _ = br.ReadByte()
+	}
+	return r, true
+}
+
+// isWide reports whether the character is an East Asian wide or fullwidth character
+// that occupies two columns.
+func isWide(r rune) bool {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return true
+	}
+	return false
+}
+
+// runeWidth returns the number of columns used by the character when it is written,
+// which is 0 for a combining mark, 2 for a wide character and otherwise 1.
+func runeWidth(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return 0
+	case isWide(r):
+		return 2 //nolint:mnd
+	}
+	return 1
+}
+
+// decode returns the character of the byte using the charset.
+func (d *Decoder) decode(b byte) rune {
+	set := d.g0
+	if d.shiftOut {
+		set = d.g1
+	}
+	if set == decGraphics {
+		if r, ok := lineDrawing(b); ok {
+			return r
+		}
+	}
+	if d.petscii {
+		return petscii(b)
+	}
+	if d.charset != nil && d.charset != charmap.XUserDefined {
+		return d.charset.DecodeByte(b)
+	}
+	return rune(b)
+}
+
+// strike returns the attribute of a character that overstrikes the character at the cursor location.
+// A repeated character is made bold, while a character over an underscore is underlined.
+func (d *Decoder) strike(b byte, attr Attribute) Attribute {
+	d.ensureLine(d.y)
+	if d.x >= len(d.currentLine) {
+		return attr
+	}
+	prev := d.currentLine[d.x]
+	switch prev.Char {
+	case '_':
+		attr.Underline = true
+	case d.decode(b):
+		attr.Bold = true
+	}
+	return attr
+}
+
+// pad expands the line with default spaces to the length.
+func (d *Decoder) pad(line []cell, length int) []cell {
+	gap := length - len(line)
+	if gap <= 0 {
+		return line
+	}
+	start := len(line)
+	line = append(line, make([]cell, gap)...)
+	blank := cell{Attr: defaultAttr(d.palette), Char: ' '}
+	for i := start; i < len(line); i++ {
+		line[i] = blank
+	}
+	return line
+}
+
+// blank returns the space used to erase a character.
+// When background color erase is used, the space uses the current background color.
+func (d *Decoder) blank() cell {
+	attr := defaultAttr(d.palette)
+	if d.bce {
+		attr.BG = d.attr.BG
+	}
+	return cell{Attr: attr, Char: ' '}
+}
+
+// bceFill reports whether erased characters need to be filled using the current background color.
+func (d *Decoder) bceFill() bool {
+	return d.bce && !attrEqual(d.blank().Attr, defaultAttr(d.palette))
+}
+
+// erase replaces the cells of the line from the column to the end column (exclusive) with blank spaces.
+func (d *Decoder) erase(line []cell, from, end int) []cell {
+	line = d.pad(line, end)
+	blank := d.blank()
+	for i := from; i < end; i++ {
+		line[i] = blank
+	}
+	return line
+}
+
 // writeChar writes a printable character at the cursor location using given attribute.
 func (d *Decoder) writeChar(b byte, attr Attribute) {
-	ch := rune(b)
-	if d.charset != nil && d.charset != charmap.XUserDefined {
-		ch = d.charset.DecodeByte(b)
+	d.writeRune(d.decode(b), attr)
+}
+
+// writeRune writes a decoded character at the cursor location using given attribute.
+func (d *Decoder) writeRune(ch rune, attr Attribute) {
+	if unicode.In(ch, unicode.Mn, unicode.Me) && d.combine(ch) {
+		return
+	}
+	d.last = &cell{Attr: attr, Char: ch}
+	if !isWide(ch) {
+		d.put(cell{Attr: attr, Char: ch})
+		return
+	}
+	// a wide character that does not fit at the end of the line wraps to the next line
+	if d.autowrap && !d.noWrap && d.x == d.width-1 {
+		d.newline()
+	}
+	x := d.x
+	d.put(cell{Attr: attr, Char: ch})
+	if d.x == x+1 {
+		d.put(cell{Attr: attr, Char: wideSpacer})
+	}
+}
+
+// combine appends the zero-width combining character to the character before the cursor,
+// such as an acute accent to the letter e. The result is false when there is no previous character,
+// which includes the start of a line that was not reached by autowrap.
+func (d *Decoder) combine(mark rune) bool {
+	line, x := d.y, d.x-1
+	if x < 0 && d.wrapped && line > 0 && d.buffer[line-1] != nil {
+		// the previous character wrapped the cursor to the next line
+		line--
+		x = len(d.buffer[line]) - 1
 	}
+	if x >= 0 && x < len(d.buffer[line]) && d.buffer[line][x].Char == wideSpacer {
+		x--
+	}
+	if x < 0 || x >= len(d.buffer[line]) {
+		return false
+	}
+	d.buffer[line][x].marks += string(mark)
+	if d.last != nil {
+		d.last.marks += string(mark)
+	}
+	return true
+}
+
+// put writes the cell at the cursor location and advances the cursor.
+func (d *Decoder) put(c cell) {
 	d.ensureLine(d.y)
 	// expand line with spaces if needed
-	for len(d.currentLine) < d.x {
-		d.currentLine = append(d.currentLine, cell{Attr: defaultAttr(d.palette), Char: ' '})
-	}
+	d.currentLine = d.pad(d.currentLine, d.x)
 	if d.x < len(d.currentLine) {
-		d.currentLine[d.x] = cell{Attr: attr, Char: ch}
+		d.currentLine[d.x] = c
 	} else {
-		d.currentLine = append(d.currentLine, cell{Attr: attr, Char: ch})
+		d.currentLine = append(d.currentLine, c)
 	}
 	d.buffer[d.y] = d.currentLine
 	d.x++
-	if d.x >= d.width {
-		d.newline()
+	if d.x < d.width || d.noWrap {
+		return
+	}
+	if !d.autowrap {
+		d.x = d.width - 1
+		return
+	}
+	d.newline()
+	d.wrapped = true
+}
+
+// formFeed moves the cursor to the start of a new page below all existing lines.
+func (d *Decoder) formFeed() {
+	d.setCursor(ptrInt(0), ptrInt(len(d.buffer)+1))
+}
+
+// tab writes spaces using given attribute to move the cursor to the next tab stop.
+// The cursor never moves past the last column of the line.
+func (d *Decoder) tab(attr Attribute) {
+	stop := d.nextTab()
+	for d.x < stop {
+		d.writeChar(' ', attr)
+	}
+}
+
+// nextTab returns the column of the next tab stop after the cursor,
+// or the last column when there are no more tab stops.
+// Without wrapping, the cursor is unchanged when there are no more tab stops.
+func (d *Decoder) nextTab() int {
+	last := d.width - 1
+	if d.noWrap {
+		// the default stops continue forever, so search one tab width beyond the last set stop
+		last = max(d.x, d.width)
+		for col := range d.tabStops {
+			last = max(last, col)
+		}
+		last += d.tabWidth
+	}
+	for col := d.x + 1; col < last; col++ {
+		if d.tabStop(col) {
+			return col
+		}
+	}
+	if d.noWrap {
+		return d.x
+	}
+	return last
+}
+
+// tabStop reports whether there is a tab stop at the column.
+func (d *Decoder) tabStop(col int) bool {
+	if set, ok := d.tabStops[col]; ok {
+		return set
 	}
+	return !d.tabsCleared && col%d.tabWidth == 0
 }
 
 func ptrInt(v int) *int { return &v }
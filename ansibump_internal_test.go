@@ -0,0 +1,30 @@
+package ansibump
+
+import (
+	"testing"
+
+	"github.com/nalgeon/be"
+)
+
+func TestBuildStyleInjection(t *testing.T) {
+	t.Parallel()
+	var def style
+	def.set(CGA16)
+	a := Attribute{FG: `a00;" onmouseover="alert(1)`, BG: "0a0"}
+	s := buildStyle(a, def)
+	be.Equal(t, s, "background-color:#0a0;")
+	be.Equal(t, sanitize(`color:#a00;" onclick="x"><script>`), "color:#a00;onclickxscript")
+	be.Equal(t, sanitize("color:#a00;background-color:#0a0;"), "color:#a00;background-color:#0a0;")
+}
+
+func TestBuildStyleInverseDefaults(t *testing.T) {
+	t.Parallel()
+	var def style
+	def.set(CGA16)
+	// an attribute without explicit colors, such as one applied to a zero value Attribute
+	a, err := ApplySGR([]int{7}, Attribute{}, CGA16)
+	be.Err(t, err, nil)
+	be.Equal(t, buildStyle(a, def), "color:#000;background-color:#aaa;")
+	a.Bold = true
+	be.Equal(t, buildStyle(a, def), "color:#555;background-color:#aaa;")
+}
@@ -3,7 +3,12 @@ package ansibump_test
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
 	"strings"
 	"testing"
 
@@ -67,6 +72,15 @@ func ExampleBytes() {
 	// Output: "<div style=\"color:#aaa;background-color:#000;\"><span style=\"color:#000;background-color:#0a0;\">HI</span></div>"
 }
 
+func ExampleFromBytes() {
+	ansi := []byte("\x1b[0m\x1b[5;30;42mHI\x1b[0m")
+	d, _ := ansibump.FromBytes(ansi, 80)
+	var b bytes.Buffer
+	_ = d.Write(&b)
+	fmt.Printf("%q", b.String())
+	// Output: "<div style=\"color:#aaa;background-color:#000;\"><span style=\"color:#000;background-color:#0a0;\">HI</span></div>"
+}
+
 func ExampleString() {
 	const ansi = "\x1b[0m\n\x1b[1;34m\x02\x1b[0m \x1b[1;34mA\x1b[36mN\x1b[33mS\x1b[37mI\x1b[35mbump\x1b[0;33m\x1b[37m"
 	r := strings.NewReader(ansi)
@@ -83,6 +97,14 @@ func ExampleString_xterm256() {
 	// Output: "<div style=\"color:#aaa;background-color:#000;\"><span style=\"color:#8700ff;\">Purple</span><span style=\"color:#aaa;\"> </span><span style=\"color:#875f00;\">Orange4</span></div>"
 }
 
+func ExampleString_xterm256Background() {
+	const ansi = "\x1b[0m\x1b[48;5;21mBlue1\x1b[0m \x1b[48;5;240mGrey35\x1b[0m"
+	r := strings.NewReader(ansi)
+	s, _ := ansibump.String(r, 80)
+	fmt.Printf("%q", s)
+	// Output: "<div style=\"color:#aaa;background-color:#000;\"><span style=\"color:#aaa;background-color:#0000ff;\">Blue1</span><span style=\"color:#aaa;\"> </span><span style=\"color:#aaa;background-color:#585858;\">Grey35</span></div>"
+}
+
 func ExampleString_rgb() {
 	const ansi = "\x1b[0m\x1b[38;2;135;0;255;48;2;135;95;0mPurple on Orange4\x1b[0m"
 	r := strings.NewReader(ansi)
@@ -118,6 +140,13 @@ func TestColor(t *testing.T) {
 	be.Equal(t, colr, ansibump.CLGreen)
 	be.Equal(t, colr.BG(), "background-color:#5f5;")
 	be.Equal(t, colr.FG(), "color:#5f5;")
+	// invalid colors
+	for _, c := range []ansibump.Color{"zzz", "", "ff", "aaaa", "12345g", "f00;x", "abcdefa"} {
+		be.True(t, !c.Valid())
+		be.Equal(t, c.FG(), "")
+		be.Equal(t, c.BG(), "")
+	}
+	be.True(t, ansibump.Color("ABCDEF").Valid())
 }
 
 func TestBasic(t *testing.T) {
@@ -194,3 +223,1517 @@ func TestRGB(t *testing.T) {
 	s = ansibump.RGBHex([]int{}, 1)
 	be.Equal(t, s, "")
 }
+
+func BenchmarkCursorForward(b *testing.B) {
+	// a sparse line built by repeated forward cursor movements
+	const repeat = 200
+	ansi := strings.Repeat("\x1b[80CX", repeat)
+	cust := ansibump.Customizer{Width: 81 * repeat}
+	for b.Loop() {
+		d := cust.NewDecoder()
+		if err := d.Read(strings.NewReader(ansi)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStreamDecoder(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[0m\x1b[1;34mline one\r\n\x1b[31mline\x1b[0m two\r\n\r\n\x1b[42mfour\x1b[0m"
+	cust := ansibump.Customizer{CharSet: charmap.CodePage437}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+
+	var stream bytes.Buffer
+	d := cust.NewStreamDecoder(&stream)
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.True(t, stream.Len() > 0)
+	be.Err(t, d.Close(), nil)
+	be.Equal(t, stream.String(), buf.String())
+
+	// upward cursor movement falls back to full buffering
+	const up = "one\r\ntwo\x1b[Bthree\x1b[AX\r\nfour"
+	buf, err = cust.Buffer(strings.NewReader(up))
+	be.Err(t, err, nil)
+	stream.Reset()
+	d = cust.NewStreamDecoder(&stream)
+	be.Err(t, d.Read(strings.NewReader(up)), nil)
+	be.Err(t, d.Close(), nil)
+	be.Equal(t, stream.String(), buf.String())
+}
+
+// cancelReader cancels the context once the first read is complete.
+type cancelReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (c cancelReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.cancel()
+	return n, err
+}
+
+func TestReadContext(t *testing.T) {
+	t.Parallel()
+	ansi := strings.Repeat("\x1b[31mHI\x1b[0m\r\n", 10000)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := cancelReader{r: strings.NewReader(ansi), cancel: cancel}
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	err := d.ReadContext(ctx, r)
+	be.Err(t, err, context.Canceled)
+
+	d = cust.NewDecoder()
+	err = d.ReadContext(context.Background(), strings.NewReader(ansi))
+	be.Err(t, err, nil)
+}
+
+func TestTab(t *testing.T) {
+	t.Parallel()
+	s, err := ansibump.String(strings.NewReader("a\tb"), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">a       b</span></div>`)
+
+	cust := ansibump.Customizer{TabWidth: 4}
+	buf, err := cust.Buffer(strings.NewReader("\x1b[31mab\tc"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#a00;">ab  c</span></div>`)
+}
+
+func TestTabClear(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Width: 20, Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[3ga\tb")), nil)
+	be.Equal(t, strings.Index(d.Text(), "b"), 19)
+
+	// clear the stop at column 8, so the tab moves to column 16
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("\x1b[8C\x1b[g\ra\tb")), nil)
+	be.Equal(t, d.Text(), "a"+strings.Repeat(" ", 15)+"b")
+	be.Err(t, d.Read(strings.NewReader("\x1b[2g")), ansibump.ErrRecognized)
+
+	cust = ansibump.Customizer{Width: ansibump.NoWrap}
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[3ga\tb")), nil)
+	be.Equal(t, d.Text(), "ab")
+}
+
+func TestTabSet(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[3g\x1b[10C\x1bH\ra\tb")), nil)
+	be.Equal(t, d.Text(), "a         b")
+
+	// the set stop is used along with the default stops
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("\x1b[10C\x1bH\ra\tb\tc\td")), nil)
+	be.Equal(t, d.Text(), "a       b c     d")
+
+	// the set stop can be cleared
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("\x1b[10C\x1bH\x1b[g\r12345678\tb")), nil)
+	be.Equal(t, d.Text(), "12345678        b")
+}
+
+func TestBackspace(t *testing.T) {
+	t.Parallel()
+	s, err := ansibump.String(strings.NewReader("abc\x08X\x08\x08\x08\x08Y"), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">YbX</span></div>`)
+
+	// overstrike is ignored unless enabled
+	cust := ansibump.Customizer{CharSet: charmap.CodePage437}
+	buf, err := cust.Buffer(strings.NewReader("_\x08ab\x08b"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">ab</span></div>`)
+
+	cust.Overstrike = true
+	buf, err = cust.Buffer(strings.NewReader("_\x08ab\x08bc"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#aaa;text-decoration:underline;">a</span>`+
+		`<span style="color:#fff;">b</span><span style="color:#aaa;">c</span></div>`)
+}
+
+func TestVerticalTab(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true, CharSet: charmap.ISO8859_1}
+	buf, err := cust.Buffer(strings.NewReader("ab\x0bcd\x0cef"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">ab</span>`+
+		"\n"+`<span style="color:#aaa;">  cd</span>`+
+		"\n"+`<span style="color:#aaa;">    ef</span></div>`)
+
+	cust.PageBreak = true
+	buf, err = cust.Buffer(strings.NewReader("ab\x0bcd\x0cef"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">ab</span>`+
+		"\n"+`<span style="color:#aaa;">  cd</span>`+
+		"\n\n"+`<span style="color:#aaa;">ef</span></div>`)
+
+	// code pages also move the cursor, which is the default CP437 path of String
+	s, err := ansibump.String(strings.NewReader("ab\x0bcd\x0cef"), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">ab</span>`+
+		"\n"+`<span style="color:#aaa;">  cd</span>`+
+		"\n"+`<span style="color:#aaa;">    ef</span></div>`)
+}
+
+func TestDeviceStatusReport(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	buf, err := cust.Buffer(strings.NewReader("\x1b[6nHI"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">HI</span></div>`)
+
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("HI\r\nthere")), nil)
+	cells := d.Cells()
+	for _, dsr := range []string{"\x1b[5n", "\x1b[6n"} {
+		be.Err(t, d.Read(strings.NewReader(dsr)), nil)
+		be.Equal(t, d.Diff(cells), []ansibump.CellChange{})
+	}
+	be.Err(t, d.Read(strings.NewReader("!")), nil)
+	be.Equal(t, d.Text(), "HI\nthere!")
+	be.Err(t, d.Read(strings.NewReader("\x1b[9n")), ansibump.ErrRecognized)
+}
+
+func TestDeviceAttributes(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	for _, ansi := range []string{"\x1b[cHI", "\x1b[0cHI", "\x1b[>cHI", "\x1b[>0cHI", "\x1b[=cHI"} {
+		buf, err := cust.Buffer(strings.NewReader(ansi))
+		be.Err(t, err, nil)
+		be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">HI</span></div>`)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	err := d.Read(strings.NewReader("\x1b]0;My Session\x07hello"))
+	be.Err(t, err, nil)
+	be.Equal(t, d.Title(), "My Session")
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">hello</span>`})
+
+	// string terminator
+	d = cust.NewDecoder()
+	err = d.Read(strings.NewReader("\x1b]2;Another\x1b\\hello\x1b]1;icon\x07"))
+	be.Err(t, err, nil)
+	be.Equal(t, d.Title(), "Another")
+}
+
+func TestReset(t *testing.T) {
+	t.Parallel()
+	const first = "\x1b]0;title\x07\x1b[31mred\r\n\x1b[sline two\x1b[5C"
+	const second = "\x1b[uHI\x1b[32mgreen"
+	cust := ansibump.Customizer{Color: ansibump.Xterm16, CharSet: charmap.CodePage437}
+	fresh := func(s string) string {
+		var b bytes.Buffer
+		d := cust.NewDecoder()
+		be.Err(t, d.Read(strings.NewReader(s)), nil)
+		be.Err(t, d.Write(&b), nil)
+		return b.String()
+	}
+	var b bytes.Buffer
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(first)), nil)
+	be.Err(t, d.Write(&b), nil)
+	be.Equal(t, b.String(), fresh(first))
+
+	b.Reset()
+	d.Reset()
+	be.Equal(t, d.Title(), "")
+	be.Err(t, d.Read(strings.NewReader(second)), nil)
+	be.Err(t, d.Write(&b), nil)
+	be.Equal(t, b.String(), fresh(second))
+}
+
+func TestCells(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[31mX")), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells), 1)
+	be.Equal(t, len(cells[0]), 1)
+	be.Equal(t, cells[0][0].Char, "X")
+	be.Equal(t, cells[0][0].Attr.FG, string(ansibump.CRed))
+	be.Equal(t, cells[0][0].Attr.BG, string(ansibump.CBlack))
+	be.True(t, !cells[0][0].Attr.Bold)
+
+	// the grid is a copy
+	cells[0][0].Char = "Y"
+	be.Equal(t, d.Cells()[0][0].Char, "X")
+
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("ab\r\n\r\n\x1b[5Cc")), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 3)
+	be.Equal(t, len(cells[0]), 2)
+	be.Equal(t, len(cells[1]), 0)
+	be.Equal(t, len(cells[2]), 6)
+}
+
+func TestAutowrap(t *testing.T) {
+	t.Parallel()
+	line := strings.Repeat("a", 80) + "b"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(line)), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells), 2)
+	be.Equal(t, len(cells[0]), 80)
+	be.Equal(t, cells[1][0].Char, "b")
+
+	cust.NoAutowrap = true
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(line)), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 1)
+	be.Equal(t, len(cells[0]), 80)
+	be.Equal(t, cells[0][79].Char, "b")
+
+	// toggle the mode at runtime
+	cust.NoAutowrap = false
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[?7l"+line+"\x1b[?7h\r\n"+line)), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 3)
+	be.Equal(t, cells[0][79].Char, "b")
+	be.Equal(t, cells[2][0].Char, "b")
+}
+
+func TestBackgroundColorErase(t *testing.T) {
+	t.Parallel()
+	const ansi = "ab\x1b[42m\x1b[K"
+	cust := ansibump.Customizer{Width: 10}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">ab</span></div>`)
+
+	cust.BackgroundColorErase = true
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells[0]), 10)
+	be.Equal(t, cells[0][1].Attr.BG, string(ansibump.CBlack))
+	for _, c := range cells[0][2:] {
+		be.Equal(t, c.Char, " ")
+		be.Equal(t, c.Attr.BG, string(ansibump.CGreen))
+	}
+
+	// erase character
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("abcdef\x1b[4D\x1b[41m\x1b[2X")), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells[0]), 6)
+	be.Equal(t, cells[0][2].Char, " ")
+	be.Equal(t, cells[0][2].Attr.BG, string(ansibump.CRed))
+	be.Equal(t, cells[0][4].Char, "e")
+}
+
+func TestEraseInLineStrict(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	buf, err := cust.Buffer(strings.NewReader("abc\x1b[2KHI"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">   HI</span></div>`)
+	_, err = cust.Buffer(strings.NewReader("abc\x1b[3KHI"))
+	be.Err(t, err, ansibump.ErrRecognized)
+}
+
+func TestEraseInDisplayFromTop(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Width: 10}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("abc\r\n\x1b[31mdefg\r\nxyz\x1b[2D\x1b[1J")), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells), 3)
+	be.Equal(t, len(cells[0]), 3)
+	be.Equal(t, len(cells[1]), 4)
+	for _, row := range cells[:2] {
+		for _, c := range row {
+			be.Equal(t, c.Char, " ")
+			be.Equal(t, c.Attr.FG, string(ansibump.CGray))
+		}
+	}
+	be.Equal(t, cells[2][1].Char, " ")
+	be.Equal(t, cells[2][2].Char, "z")
+}
+
+func TestRepeatChar(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	buf, err := cust.Buffer(strings.NewReader("\x1b[31mX\x1b[0m\x1b[5b"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#a00;">XXXXXX</span></div>`)
+	buf, err = cust.Buffer(strings.NewReader("\x1b[bX\x1b[b"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">XX</span></div>`)
+}
+
+func TestImpliedSGR(t *testing.T) {
+	t.Parallel()
+	for _, strict := range []bool{false, true} {
+		cust := ansibump.Customizer{Strict: strict}
+		d := cust.NewDecoder()
+		be.Err(t, d.Read(strings.NewReader("\x1b[1;;31mX")), nil)
+		attr := d.Cells()[0][0].Attr
+		be.Equal(t, attr.Bold, false)
+		be.Equal(t, attr.FG, string(ansibump.CRed))
+	}
+	cust := ansibump.Customizer{Strict: true}
+	_, err := cust.Buffer(strings.NewReader("\x1b[1;;2HX"))
+	be.Err(t, err, ansibump.ErrParam)
+}
+
+func TestConceal(t *testing.T) {
+	t.Parallel()
+	s, err := ansibump.String(strings.NewReader("\x1b[8;31;42mpw\x1b[28mX\x1b[0;8mY"), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#0a0;background-color:#0a0;">pw</span>`+
+		`<span style="color:#a00;background-color:#0a0;">X</span>`+
+		`<span style="color:#000;">Y</span></div>`)
+}
+
+func TestXterm256Background(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		ansi string
+		bg   string
+	}{
+		{"\x1b[48;5;21mX", "0000ff"},
+		{"\x1b[48;5;240mX", "585858"},
+		{"\x1b[48;5;7mX", string(ansibump.CGray)},
+		{"\x1b[48;5;8mX", string(ansibump.CDarkGray)},
+		{"\x1b[48;5;15mX", string(ansibump.CWhite)},
+		{"\x1b[48;5;16mX", "000000"},
+	}
+	for _, tt := range tests {
+		cust := ansibump.Customizer{}
+		d := cust.NewDecoder()
+		be.Err(t, d.Read(strings.NewReader(tt.ansi)), nil)
+		be.Equal(t, d.Cells()[0][0].Attr.BG, tt.bg)
+	}
+}
+
+func TestXterm256(t *testing.T) {
+	t.Parallel()
+	table := ansibump.Xterm256(ansibump.CGA16)
+	be.Equal(t, table[0], ansibump.CBlack)
+	be.Equal(t, table[9], ansibump.CLRed)
+	be.Equal(t, table[16], ansibump.Color("000000"))
+	be.Equal(t, table[196], ansibump.Color("ff0000"))
+	be.Equal(t, table[255], ansibump.Color("eeeeee"))
+	table = ansibump.Xterm256(ansibump.Xterm16)
+	be.Equal(t, table[1], ansibump.XMarron)
+	be.Equal(t, table[196], ansibump.Color("ff0000"))
+	for code, c := range table {
+		be.Equal(t, string(c), ansibump.XtermHex(code, ansibump.Xterm16))
+	}
+}
+
+func TestParseSGR(t *testing.T) {
+	t.Parallel()
+	attr, err := ansibump.ParseSGR("1;31", ansibump.Attribute{}, ansibump.CGA16)
+	be.Err(t, err, nil)
+	be.Equal(t, attr, ansibump.Attribute{FG: string(ansibump.CRed), Bold: true})
+	attr, err = ansibump.ParseSGR("1;;42", attr, ansibump.CGA16)
+	be.Err(t, err, nil)
+	be.Equal(t, attr, ansibump.Attribute{FG: string(ansibump.CGray), BG: string(ansibump.CGreen)})
+	attr, err = ansibump.ParseSGR("", attr, ansibump.CGA16)
+	be.Err(t, err, nil)
+	be.Equal(t, attr, ansibump.Attribute{FG: string(ansibump.CGray), BG: string(ansibump.CBlack)})
+	_, err = ansibump.ParseSGR("1;red", attr, ansibump.CGA16)
+	be.Err(t, err, ansibump.ErrRecognized)
+}
+
+func TestAttributeSGR(t *testing.T) {
+	t.Parallel()
+	attr := ansibump.Attribute{FG: string(ansibump.CRed), BG: string(ansibump.CGreen), Bold: true}
+	be.Equal(t, attr.SGR(), []int{1, 31, 42})
+	be.Equal(t, ansibump.Attribute{}.SGR(), []int{})
+	tests := []struct {
+		attr ansibump.Attribute
+		pal  ansibump.Palette
+		want []int
+	}{
+		{attr, ansibump.CGA16, []int{1, 31, 42}},
+		{ansibump.Attribute{FG: string(ansibump.CYellow), Underline: true, Inverse: true}, ansibump.CGA16, []int{4, 7, 93}},
+		{ansibump.Attribute{FG: string(ansibump.XMarron), BG: string(ansibump.XBlue)}, ansibump.Xterm16, []int{31, 104}},
+		{ansibump.Attribute{FG: "ff8700", Faint: true, Conceal: true}, ansibump.CGA16, []int{2, 8, 38, 5, 208}},
+		{ansibump.Attribute{BG: "123456", Double: true}, ansibump.CGA16, []int{21, 48, 2, 0x12, 0x34, 0x56}},
+	}
+	for _, tt := range tests {
+		params := tt.attr.SGR()
+		be.Equal(t, params, tt.want)
+		got, err := ansibump.ApplySGR(params, ansibump.Attribute{}, tt.pal)
+		be.Err(t, err, nil)
+		be.Equal(t, got, tt.attr)
+	}
+
+	// the decoded cells include the explicit default colors of the palette
+	cust := ansibump.Customizer{Color: ansibump.Xterm16}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("a\x1b[1;31mb\x1b[0;44mc")), nil)
+	cells := d.Cells()[0]
+	be.Equal(t, cells[0].Attr.SGR(), []int{37, 40})
+	reset, err := ansibump.ApplySGR([]int{0}, ansibump.Attribute{}, ansibump.Xterm16)
+	be.Err(t, err, nil)
+	want := [][]int{{}, {1, 31}, {44}}
+	for i, c := range cells {
+		params := c.Attr.PaletteSGR(ansibump.Xterm16)
+		be.Equal(t, params, want[i])
+		got, err := ansibump.ApplySGR(params, reset, ansibump.Xterm16)
+		be.Err(t, err, nil)
+		be.Equal(t, got, c.Attr)
+	}
+}
+
+func TestNearestColor(t *testing.T) {
+	t.Parallel()
+	be.Equal(t, ansibump.NearestColor("#fe0102", ansibump.CGA16), ansibump.CRed)
+	be.Equal(t, ansibump.NearestColor("fe0102", ansibump.Xterm16), ansibump.XRed)
+	be.Equal(t, ansibump.NearestColor("7f7f7f", ansibump.CGA16), ansibump.CDarkGray)
+	be.Equal(t, ansibump.NearestColor("red", ansibump.CGA16), ansibump.Color(""))
+
+	const ansi = "\x1b[38;2;254;1;2;48;2;0;0;200mQ\x1b[38;5;208mX"
+	cust := ansibump.Customizer{Quantize16: true}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#a00;background-color:#00a;">Q</span>`))
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#ff8700;background-color:#00a;">X</span>`))
+}
+
+func TestNearestXterm(t *testing.T) {
+	t.Parallel()
+	code := ansibump.NearestXterm("#7f7f7e")
+	be.True(t, 232 <= code && code <= 255)
+	be.Equal(t, ansibump.NearestXterm("ff8700"), 208)
+	be.Equal(t, ansibump.NearestXterm("fe0102"), 196)
+	be.Equal(t, ansibump.NearestXterm("red"), -1)
+
+	const ansi = "\x1b[38;2;254;135;1mQ"
+	cust := ansibump.Customizer{Quantize256: true}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#ff8700;">Q</span>`))
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("HELLO\r\nWORLD")), nil)
+	prev := d.Cells()
+	be.Equal(t, d.Diff(prev), []ansibump.CellChange{})
+
+	be.Err(t, d.Read(strings.NewReader("\x1b[1;2H\x1b[31ma")), nil)
+	changes := d.Diff(prev)
+	be.Equal(t, len(changes), 1)
+	be.Equal(t, changes[0].Row, 0)
+	be.Equal(t, changes[0].Col, 1)
+	be.Equal(t, changes[0].Cell.Char, "a")
+	be.Equal(t, changes[0].Cell.Attr.FG, string(ansibump.CRed))
+
+	prev = d.Cells()
+	be.Err(t, d.Read(strings.NewReader("\x1b[2;5H\x1b[K!")), nil)
+	be.Equal(t, d.Diff(prev), []ansibump.CellChange{
+		{Row: 1, Col: 4, Cell: ansibump.Cell{Attr: changes[0].Cell.Attr, Char: "!"}},
+	})
+	d.Reset()
+	changes = d.Diff(prev)
+	be.Equal(t, len(changes), 10)
+	be.True(t, changes[0].Removed)
+}
+
+func TestCubeSteps(t *testing.T) {
+	t.Parallel()
+	r, g, b := ansibump.XtermColor(100)
+	be.Equal(t, []int{r, g, b}, []int{135, 135, 0})
+	websafe := [6]int{0, 51, 102, 153, 204, 255}
+	r, g, b = ansibump.XtermCube(100, websafe)
+	be.Equal(t, []int{r, g, b}, []int{102, 102, 0})
+	r, g, b = ansibump.XtermCube(232, websafe)
+	be.Equal(t, []int{r, g, b}, []int{-1, -1, -1})
+
+	const ansi = "\x1b[38;5;100mA\x1b[38;5;1mB\x1b[38;5;240mC"
+	cust := ansibump.Customizer{}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#878700;">A</span>`))
+	cust.CubeSteps = websafe
+	buf, err = cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#666600;">A</span>`+
+		`<span style="color:#a00;">B</span><span style="color:#585858;">C</span>`))
+
+	// the quantized true colors use the same cube steps
+	cust.Quantize256 = true
+	buf, err = cust.Buffer(strings.NewReader("\x1b[38;2;100;100;0mQ"))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#666600;">Q</span>`))
+
+	// steps out of range are clamped, or rejected when checked
+	cust = ansibump.Customizer{CubeSteps: [6]int{0, 51, 102, 153, 204, 300}}
+	_, err = cust.NewDecoderChecked()
+	be.Err(t, err, ansibump.ErrCubeSteps)
+	buf, err = cust.Buffer(strings.NewReader("\x1b[38;5;231mW"))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#ffffff;">W</span>`))
+}
+
+func TestBoldLighten(t *testing.T) {
+	t.Parallel()
+	be.Equal(t, ansibump.Lighten("ff8700"), ansibump.Color("ffaf55"))
+	be.Equal(t, ansibump.Lighten("000"), ansibump.Color("555555"))
+	be.Equal(t, ansibump.Lighten("red"), ansibump.Color(""))
+
+	const ansi = "\x1b[1;38;5;208mX\x1b[0;1;38;2;0;0;120mY\x1b[0;1;31mR\x1b[93mB"
+	cust := ansibump.Customizer{}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span style="color:#ffaf55;">X</span>`+
+		`<span style="color:#5555a5;">Y</span><span style="color:#f55;">R</span><span style="color:#ff5;">B</span>`))
+}
+
+func TestSupported(t *testing.T) {
+	t.Parallel()
+	csi := ansibump.SupportedCSI()
+	be.True(t, bytes.Contains(csi, []byte("H")))
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	for _, final := range csi {
+		err := d.ApplyCSI(final, []int{1})
+		be.True(t, !errors.Is(err, ansibump.ErrUnknownCSI))
+	}
+	be.Err(t, d.ApplyCSI('Z', nil), ansibump.ErrUnknownCSI)
+
+	sgr := ansibump.SupportedSGR()
+	be.True(t, slices.Contains(sgr, 31))
+	be.True(t, slices.IsSorted(sgr))
+	be.True(t, !slices.Contains(sgr, 5))
+}
+
+func TestControlPictures(t *testing.T) {
+	t.Parallel()
+	const ansi = "a\x03b\x7fc"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Cells()[0][1].Char, " ")
+
+	cust.ControlPictures = true
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">a␃b␡c</span>`})
+	be.Equal(t, len(d.Warnings()), 1)
+
+	cust.CharSet = charmap.CodePage437
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Cells()[0][1].Char, "\x03")
+}
+
+func TestNewDecoderChecked(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Color: ansibump.Palette(99)}
+	_, err := cust.NewDecoderChecked()
+	be.Err(t, err, ansibump.ErrUnknownPalette)
+	cust = ansibump.Customizer{Width: -2}
+	_, err = cust.NewDecoderChecked()
+	be.Err(t, err, ansibump.ErrInvalidWidth)
+
+	for _, width := range []int{0, 80, ansibump.NoWrap} {
+		cust = ansibump.Customizer{Width: width, Color: ansibump.DP2}
+		d, err := cust.NewDecoderChecked()
+		be.Err(t, err, nil)
+		be.Err(t, d.Read(strings.NewReader("HI")), nil)
+	}
+}
+
+func TestColorStats(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31;44mRRR\x1b[0mdd\x1b[7mi"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	fg, bg := d.ColorStats()
+	be.Equal(t, fg, map[string]int{"a00": 3, "aaa": 2, "000": 1})
+	be.Equal(t, bg, map[string]int{"00a": 3, "000": 2, "aaa": 1})
+	d.Reset()
+	fg, bg = d.ColorStats()
+	be.Equal(t, len(fg), 0)
+	be.Equal(t, len(bg), 0)
+}
+
+func TestMaxBlankRuns(t *testing.T) {
+	t.Parallel()
+	const ansi = "top\r\n\r\n\r\n   \r\n\r\n\r\nmiddle\r\n\r\nend"
+	cust := ansibump.Customizer{MaxBlankRuns: 1}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">top</span>`,
+		``,
+		`<span style="color:#aaa;">middle</span>`,
+		``,
+		`<span style="color:#aaa;">end</span>`,
+	})
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, strings.Count(buf.String(), "\n"), 4)
+
+	var b strings.Builder
+	sd := cust.NewStreamDecoder(&b)
+	be.Err(t, sd.Read(strings.NewReader(ansi)), nil)
+	be.Err(t, sd.Close(), nil)
+	be.Equal(t, b.String(), buf.String())
+
+	// lines filled with a background color are not blank
+	const bce = "top\r\n\x1b[44m\x1b[K\r\n\x1b[K\r\n\x1b[0m\r\n\r\nend"
+	cust = ansibump.Customizer{MaxBlankRuns: 1, BackgroundColorErase: true}
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(bce)), nil)
+	be.Equal(t, len(d.Lines(ansibump.CGA16)), 5)
+}
+
+func TestFixedGrid(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31mred\x1b[0m  \x1b[44m你\x1b[0m"
+	cust := ansibump.Customizer{FixedGrid: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="display:inline-block;width:3ch;color:#a00;">red</span>` +
+			`<span style="display:inline-block;width:2ch;color:#aaa;">  </span>` +
+			`<span style="display:inline-block;width:2ch;color:#aaa;background-color:#00a;">你</span>`,
+	})
+
+	cust.Minify = true
+	buf, err := cust.Buffer(strings.NewReader("\x1b[31mred\x1b[0m  "))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(),
+		`<span style="display:inline-block;width:3ch;color:#a00">red</span>`+
+			`<span style="display:inline-block;width:2ch">  </span>`))
+
+	cust = ansibump.Customizer{FixedGrid: true, Tailwind: true}
+	buf, err = cust.Buffer(strings.NewReader("red"))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span class="inline-block w-[3ch] text-[#aaa] bg-[#000]">red</span>`))
+}
+
+func TestCUPColRow(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[5;10Hx"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells), 5)
+	be.Equal(t, cells[4][9].Char, "x")
+
+	cust.CUPColRow = true
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 10)
+	be.Equal(t, cells[9][4].Char, "x")
+}
+
+func TestMaxSpansPerLine(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31ma\x1b[32mb\x1b[31mc\x1b[32md\x1b[0m<\r\n\x1b[31mred\x1b[0m"
+	cust := ansibump.Customizer{MaxSpansPerLine: 4}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span data-fg="a00 0a0 a00 0a0 aaa" data-bg="000 000 000 000 000">abcd&lt;</span>`,
+		`<span style="color:#a00;">red</span>`,
+	})
+	cust.MaxSpansPerLine = 5
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, strings.Count(d.Lines(ansibump.CGA16)[0], "<span"), 5)
+}
+
+func TestSetCellHook(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[31mtop\x1b[0m secret")), nil)
+	d.SetCellHook(func(c ansibump.Cell) ansibump.Cell {
+		if c.Char != " " {
+			c.Char = "*"
+		}
+		return c
+	})
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#a00;">***</span><span style="color:#aaa;"> ******</span>`,
+	})
+	var b strings.Builder
+	be.Err(t, d.Write(&b), nil)
+	be.True(t, strings.Contains(b.String(), "******"))
+	be.Equal(t, d.Text(), "top secret")
+
+	d.SetCellHook(func(c ansibump.Cell) ansibump.Cell {
+		c.Attr.FG = "0f0"
+		return c
+	})
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#0f0;">top secret</span>`})
+	d.SetCellHook(nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#a00;">top</span><span style="color:#aaa;"> secret</span>`,
+	})
+}
+
+func TestTooltips(t *testing.T) {
+	t.Parallel()
+	const ansi = "plain\x1b[38;2;255;135;0mtrue\x1b[0;1;31mred"
+	cust := ansibump.Customizer{Tooltips: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">plain</span>` +
+			`<span style="color:#ff8700;" title="#ff8700">true</span>` +
+			`<span style="color:#f55;" title="#aa0000">red</span>`,
+	})
+	cust.Tooltips = false
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.True(t, !strings.Contains(d.Lines(ansibump.CGA16)[0], "title="))
+}
+
+func TestCursorVerticalRelative(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[2;5Ha\x1b[3eb\x1b[ec")), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells), 6)
+	be.Equal(t, cells[1][4].Char, "a")
+	be.Equal(t, cells[4][5].Char, "b")
+	be.Equal(t, cells[5][6].Char, "c")
+	be.Err(t, d.Read(strings.NewReader("\x1b[1;2e")), ansibump.ErrExpect0or1)
+}
+
+func TestCursorHorizontalRelative(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("a\x1b[4ab\x1b[ac")), nil)
+	be.Equal(t, d.Text(), "a    b c")
+	be.Err(t, d.Read(strings.NewReader("\x1b[1;2a")), ansibump.ErrExpect0or1)
+
+	cust = ansibump.Customizer{Width: 4, AutoWidth: true}
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("a\x1b[4ab")), nil)
+	be.Equal(t, d.Text(), "a    b")
+}
+
+func TestReadAll(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.ReadAll(
+		strings.NewReader("one\x1b"),
+		strings.NewReader("[3"),
+		strings.NewReader("1mtwo\r\n\xe4\xbd"),
+		strings.NewReader("\xa0"),
+	), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">one</span><span style="color:#a00;">two</span>`,
+		`<span style="color:#a00;">你</span>`,
+	})
+	be.Err(t, d.ReadAll(strings.NewReader("a"), nil), ansibump.ErrReader)
+	be.Err(t, d.ReadAll(), nil)
+}
+
+func TestBareText(t *testing.T) {
+	t.Parallel()
+	const ansi = "plain <\x1b[31mred\x1b[0m plain\x1b[1m bold"
+	cust := ansibump.Customizer{BareText: true}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;">`+
+		`plain &lt;<span style="color:#a00;">red</span> plain<span style="color:#fff;"> bold</span></div>`)
+	be.True(t, !strings.Contains(buf.String(), `<span style="color:#aaa;">`))
+
+	cust.Minify = true
+	buf, err = cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `#000">plain &lt;<span style="color:#a00">red</span> plain<span`))
+}
+
+func TestWriteLines(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[0m\r\n\x1b[1;34m\x02\x1b[0m \x1b[1;34mA\x1b[36mN\x1b[7;33mS\x1b[37mI\x1b[35mbump\r\n\r\n<&>\x1b[0;33m\x1b[37m"
+	cust := ansibump.Customizer{CharSet: charmap.CodePage437}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	var b bytes.Buffer
+	be.Err(t, d.Write(&b), nil)
+	// the golden output of Write before the spans were streamed to the writer
+	const want = `<div style="color:#aaa;background-color:#000;">` + "\n" +
+		`<span style="color:#55f;">` + "\x02" + `</span><span style="color:#aaa;"> </span>` +
+		`<span style="color:#55f;">A</span><span style="color:#5ff;">N</span>` +
+		`<span style="color:#555;background-color:#a50;">S</span>` +
+		`<span style="color:#555;background-color:#aaa;">I</span>` +
+		`<span style="color:#555;background-color:#a0a;">bump</span>` + "\n\n" +
+		`<span style="color:#555;background-color:#a0a;">&lt;&amp;&gt;</span></div>`
+	be.Equal(t, b.String(), want)
+}
+
+func BenchmarkWrite(b *testing.B) {
+	ansi := strings.Repeat("\x1b[1;31mHello\x1b[0;42m world\x1b[0m and \x1b[7mreverse\x1b[0m\r\n", 5000)
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	if err := d.Read(strings.NewReader(ansi)); err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		if err := d.Write(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	ansi := strings.Repeat("\x1b[1;31mHello\x1b[0;42m world\x1b[0m and \x1b[7mreverse\x1b[0m\r\n", 500)
+	cust := ansibump.Customizer{}
+	b.Run("new", func(b *testing.B) {
+		for b.Loop() {
+			d := cust.NewDecoder()
+			if err := d.Read(strings.NewReader(ansi)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		// the lines are reused after each reset
+		d := cust.NewDecoder()
+		for b.Loop() {
+			d.Reset()
+			if err := d.Read(strings.NewReader(ansi)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestResetReusesLines(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[31mfirst\r\nlines")), nil)
+	cells := d.Cells()
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("ab")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">ab</span>`})
+	// the copy is unchanged by the reused lines
+	be.Equal(t, cells[0][0].Char, "f")
+	be.Equal(t, cells[1][0].Char, "l")
+}
+
+func TestMergeSpans(t *testing.T) {
+	t.Parallel()
+	// inverse red on green looks the same as green on red
+	const ansi = "\x1b[7;31;42mab\x1b[0;32;41mcd\x1b[0;1;30mef\x1b[0;90mgh"
+	s, err := ansibump.String(strings.NewReader(ansi), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, strings.Count(s, "<span"), 2)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#0a0;background-color:#a00;">abcd</span>`+
+		`<span style="color:#555;">efgh</span></div>`)
+}
+
+func TestMinify(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[48;2;170;170;170mA\x1b[0;38;2;171;205;239mB\x1b[0mC\x1b[31;44mD"
+	cust := ansibump.Customizer{Minify: true}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000">`+
+		`<span style="background-color:#aaa">A</span>`+
+		`<span style="color:#abcdef">B</span>`+
+		`<span>C</span>`+
+		`<span style="color:#a00;background-color:#00a">D</span></div>`)
+}
+
+func TestUpperHex(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[38;2;255;0;0;48;5;21mX"
+	cust := ansibump.Customizer{}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;"><span style="color:#ff0000;background-color:#0000ff;">X</span></div>`)
+	cust.UpperHex = true
+	buf, err = cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#AAA;background-color:#000;"><span style="color:#FF0000;background-color:#0000FF;">X</span></div>`)
+	cust.Minify = true
+	buf, err = cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#AAA;background-color:#000"><span style="color:#F00;background-color:#00F">X</span></div>`)
+
+	cust = ansibump.Customizer{UpperHex: true, Tailwind: true, Tooltips: true}
+	buf, err = cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div class="text-[#AAA] bg-[#000]">`+
+		`<span class="text-[#FF0000] bg-[#0000FF]" title="#FF0000">X</span></div>`)
+	cust = ansibump.Customizer{UpperHex: true, MaxSpansPerLine: 1}
+	buf, err = cust.Buffer(strings.NewReader(ansi + "\x1b[0mY"))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `data-fg="FF0000 AAA" data-bg="0000FF 000"`))
+}
+
+func TestBoldFaint(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[1mA\x1b[2mB\x1b[22mC\x1b[1m\x1b[21mD\x1b[24mE")), nil)
+	row := d.Cells()[0]
+	be.True(t, row[0].Attr.Bold && !row[0].Attr.Faint)
+	be.True(t, row[1].Attr.Bold && row[1].Attr.Faint)
+	be.True(t, !row[2].Attr.Bold && !row[2].Attr.Faint)
+	be.True(t, row[3].Attr.Bold && row[3].Attr.Double && !row[3].Attr.Underline)
+	be.True(t, row[4].Attr.Bold && !row[4].Attr.Double)
+
+	s, err := ansibump.String(strings.NewReader("\x1b[2;37mA\x1b[0;21mB"), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#555555;">A</span>`+
+		`<span style="color:#aaa;text-decoration:underline;text-decoration-style:double;">B</span></div>`)
+	be.Equal(t, ansibump.Dim(ansibump.CWhite), "7f7f7f")
+	be.Equal(t, ansibump.Dim("xyz"), "")
+}
+
+func TestTrimTrailing(t *testing.T) {
+	t.Parallel()
+	const ansi = "ab   \r\n\x1b[3C\x1b[31mx\x1b[0m  \x1b[5C\r\n\x1b[42m  \x1b[0m  "
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">ab   </span>`,
+		`<span style="color:#aaa;">   </span><span style="color:#a00;">x</span><span style="color:#aaa;">  </span>`,
+		`<span style="color:#aaa;background-color:#0a0;">  </span><span style="color:#aaa;">  </span>`,
+	})
+	cust.TrimTrailing = true
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">ab</span>`,
+		`<span style="color:#aaa;">   </span><span style="color:#a00;">x</span>`,
+		`<span style="color:#aaa;background-color:#0a0;">  </span>`,
+	})
+}
+
+func TestNULSpace(t *testing.T) {
+	t.Parallel()
+	const ansi = "a\x00\x00b"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, len(d.Cells()[0]), 2)
+	be.Equal(t, d.Cells()[0][1].Char, "b")
+
+	cust.NULSpace = true
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, len(d.Cells()[0]), 4)
+	be.Equal(t, d.Cells()[0][3].Char, "b")
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">a  b</span>`})
+}
+
+func TestCarriageReturn(t *testing.T) {
+	t.Parallel()
+	s, err := ansibump.String(strings.NewReader("abc\rX"), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">Xbc</span></div>`)
+	s, err = ansibump.String(strings.NewReader("abc\r\ndef\n\rghi"), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;"><span style="color:#aaa;">abc</span>`+
+		"\n"+`<span style="color:#aaa;">def</span>`+
+		"\n"+`<span style="color:#aaa;">ghi</span></div>`)
+}
+
+func TestModeEnabled(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.True(t, d.ModeEnabled(7))
+	be.True(t, !d.ModeEnabled(2004))
+	be.Err(t, d.Read(strings.NewReader("\x1b[?2004h\x1b[?1000;25hHI\x1b[?25l\x1b[?7l")), nil)
+	be.True(t, d.ModeEnabled(2004))
+	be.True(t, d.ModeEnabled(1000))
+	be.True(t, !d.ModeEnabled(25))
+	be.True(t, !d.ModeEnabled(7))
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">HI</span>`})
+	d.Reset()
+	be.True(t, !d.ModeEnabled(2004))
+	be.True(t, d.ModeEnabled(7))
+}
+
+func TestDebugAttrs(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[1;31;42mX\x1b[4mY\x1b[0mZ"
+	cust := ansibump.Customizer{DebugAttrs: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#f55;background-color:#0a0;" data-sgr="1;31;42">X</span>` +
+			`<span style="color:#f55;background-color:#0a0;text-decoration:underline;" data-sgr="1;4;31;42">Y</span>` +
+			`<span style="color:#aaa;" data-sgr="0">Z</span>`,
+	})
+	// the parameters are the active state and not the history of the sequences
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[31mA\x1b[32mB\x1b[31mC\x1b[32mD\x1b[1;22mE")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#a00;" data-sgr="31">A</span><span style="color:#0a0;" data-sgr="32">B</span>` +
+			`<span style="color:#a00;" data-sgr="31">C</span><span style="color:#0a0;" data-sgr="32">DE</span>`,
+	})
+	cust.DebugAttrs = false
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.True(t, !strings.Contains(d.Lines(ansibump.CGA16)[0], "data-sgr"))
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("ab\r\ncd")), nil)
+	frame1, err := d.Snapshot()
+	be.Err(t, err, nil)
+	be.Equal(t, frame1, `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#aaa;">ab</span>`+"\n"+`<span style="color:#aaa;">cd</span></div>`)
+
+	// the cursor position is kept between reads
+	be.Err(t, d.Read(strings.NewReader("\x1b[AX")), nil)
+	frame2, err := d.Snapshot()
+	be.Err(t, err, nil)
+	be.Equal(t, frame2, `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#aaa;">abX</span>`+"\n"+`<span style="color:#aaa;">cd</span></div>`)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">abX</span>`,
+		`<span style="color:#aaa;">cd</span>`,
+	})
+}
+
+func TestReadKeepsAttribute(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[31m")), nil)
+	be.Err(t, d.Read(strings.NewReader("text")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#a00;">text</span>`})
+
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("text")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">text</span>`})
+}
+
+func TestCSIIntermediate(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("a\x1b[1 qb\x1b[31mc")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">ab</span><span style="color:#a00;">c</span>`,
+	})
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[!p")), ansibump.ErrUnknownCSI)
+
+	cust.Strict = false
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("a\x1b[!pb\x1b[$}c")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">abc</span>`})
+}
+
+func TestCursorShape(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Equal(t, d.CursorShape(), 0)
+	be.Err(t, d.Read(strings.NewReader("a\x1b[3 qb")), nil)
+	be.Equal(t, d.CursorShape(), 3)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">ab</span>`})
+	be.Err(t, d.Read(strings.NewReader("\x1b[ q")), nil)
+	be.Equal(t, d.CursorShape(), 0)
+	be.Err(t, d.Read(strings.NewReader("\x1b[5 q")), nil)
+	d.Reset()
+	be.Equal(t, d.CursorShape(), 0)
+	be.Err(t, d.Read(strings.NewReader("\x1b[9 q")), ansibump.ErrRecognized)
+}
+
+func TestEscapeIndex(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("one\x1bMtwo")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">   two</span>`,
+		`<span style="color:#aaa;">one</span>`,
+	})
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("ab\x1bDc\x1bEd\x1bMe")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">ab</span>`,
+		`<span style="color:#aaa;"> ec</span>`,
+		`<span style="color:#aaa;">d</span>`,
+	})
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1bZ")), ansibump.ErrUnknownEsc)
+}
+
+func TestEscapeSaveCursor(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31mab\x1b7\x1b[32m\r\ncd\x1b8ef"
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#a00;">abef</span>`,
+		`<span style="color:#0a0;">cd</span>`,
+	})
+	// a restore without a save moves home using the default attribute
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[31mab\x1b8c")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">c</span><span style="color:#a00;">b</span>`,
+	})
+}
+
+func TestEscapeReset(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31mold\r\nlines\x1bcnew"
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">new</span>`})
+
+	var b strings.Builder
+	d = cust.NewStreamDecoder(&b)
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Err(t, d.Close(), nil)
+	be.Equal(t, b.String(), `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#a00;">old</span>`+"\n"+`<span style="color:#aaa;">new</span></div>`)
+}
+
+func TestReadBOM(t *testing.T) {
+	t.Parallel()
+	const ansi = "\xef\xbb\xbfHello"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">Hello</span>`})
+	// code pages display the byte order mark as characters
+	cust.CharSet = charmap.CodePage437
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">∩╗┐Hello</span>`})
+}
+
+func TestWarnings(t *testing.T) {
+	t.Parallel()
+	const ansi = "ab\x1b[1;2Acd\x1b[5y"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Warnings(), []string{
+		`offset 2: "\x1b[1;2A": CUU A: expected 0 or 1 parameters: [1 2]`,
+		`offset 10: "\x1b[5y": unrecognized CSI final byte: y`,
+	})
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">abcd</span>`})
+	d.Reset()
+	be.Equal(t, len(d.Warnings()), 0)
+
+	// a reset to the initial state by ESC c keeps the earlier warnings and offsets
+	be.Err(t, d.Read(strings.NewReader("\x1b[5yab\x1bcok\x1b[7y")), nil)
+	be.Equal(t, d.Warnings(), []string{
+		`offset 0: "\x1b[5y": unrecognized CSI final byte: y`,
+		`offset 10: "\x1b[7y": unrecognized CSI final byte: y`,
+	})
+	be.Equal(t, d.Text(), "ok")
+}
+
+func TestDecodeError(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	err := d.Read(strings.NewReader("hello\r\n\x1b[1;2;3H"))
+	be.Err(t, err, ansibump.ErrExpect0or2)
+	var de *ansibump.DecodeError
+	be.True(t, errors.As(err, &de))
+	be.Equal(t, de.Offset, 7)
+	be.Equal(t, de.Sequence, []byte("\x1b[1;2;3H"))
+	be.Equal(t, err.Error(), `offset 7: "\x1b[1;2;3H": CUP H/f: expected 0 or 2 parameters: [1 2 3]`)
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	be.Equal(t, len(ansibump.Validate(strings.NewReader("\x1b[1;31mvalid\x1b[0m\r\n"))), 0)
+	errs := ansibump.Validate(strings.NewReader("a\x1b[1;2Ab\x1b[;5Hc"))
+	be.Equal(t, len(errs), 2)
+	be.Equal(t, errs[0].Offset, 1)
+	be.Err(t, errs[0].Err, ansibump.ErrExpect0or1)
+	be.Equal(t, errs[1].Offset, 8)
+	be.Equal(t, errs[1].Sequence, []byte("\x1b[;5H"))
+	be.Err(t, errs[1].Err, ansibump.ErrParam)
+	errs = ansibump.Validate(strings.NewReader("\x1b[1;2Abad\x1bcok"))
+	be.Equal(t, len(errs), 1)
+	be.Equal(t, errs[0].Offset, 0)
+	be.Err(t, errs[0].Err, ansibump.ErrExpect0or1)
+	errs = ansibump.Validate(nil)
+	be.Equal(t, len(errs), 1)
+	be.Err(t, errs[0].Err, ansibump.ErrReader)
+}
+
+func TestBell(t *testing.T) {
+	t.Parallel()
+	const ansi = "a\x07b\x07"
+	cust := ansibump.Customizer{Strict: true, CharSet: charmap.CodePage437}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">ab</span>`})
+
+	rings := 0
+	cust.Bell = func() { rings++ }
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">ab</span>`})
+	be.Equal(t, rings, 2)
+}
+
+func TestWriteMarkdownHTML(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("  one\r\n\x1b[31mtwo")), nil)
+	var b strings.Builder
+	be.Err(t, d.WriteMarkdownHTML(&b), nil)
+	be.Equal(t, b.String(), `<div style="color:#aaa;background-color:#000;white-space:pre;">`+
+		`<span style="color:#aaa;">  one</span><br><span style="color:#a00;">two</span></div>`)
+	be.True(t, !strings.Contains(b.String(), "\n"))
+}
+
+func TestContrastBoost(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[1;30mX\x1b[0mY\x1b[34;47mZ"
+	cust := ansibump.Customizer{ContrastBoost: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#777777;">X</span><span style="color:#aaa;">Y</span>` +
+			`<span style="color:#00a;background-color:#aaa;">Z</span>`,
+	})
+}
+
+func TestMonochrome(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31mred\x1b[0m \x1b[4;44mblue"
+	cust := ansibump.Customizer{Monochrome: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#535353;">red</span><span style="color:#aaa;"> </span>` +
+			`<span style="color:#aaa;background-color:#303030;text-decoration:underline;">blue</span>`,
+	})
+	be.Equal(t, ansibump.Gray(ansibump.CRed), "535353")
+	be.Equal(t, ansibump.Gray("fff"), "fff")
+	be.Equal(t, ansibump.Gray("xyz"), "")
+	s, err := d.Snapshot()
+	be.Err(t, err, nil)
+	be.True(t, strings.HasPrefix(s, `<div style="color:#aaa;background-color:#000;">`))
+}
+
+func TestInvertAll(t *testing.T) {
+	t.Parallel()
+	const ansi = "ab\x1b[31;44mcd\x1b[7mef"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	s, err := d.Snapshot()
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#aaa;">ab</span><span style="color:#a00;background-color:#00a;">cd</span>`+
+		`<span style="color:#00a;background-color:#a00;">ef</span></div>`)
+
+	cust.InvertAll = true
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	s, err = d.Snapshot()
+	be.Err(t, err, nil)
+	be.Equal(t, s, `<div style="color:#000;background-color:#aaa;">`+
+		`<span style="color:#000;background-color:#aaa;">ab</span><span style="color:#00a;background-color:#a00;">cd</span>`+
+		`<span style="color:#a00;background-color:#00a;">ef</span></div>`)
+}
+
+func TestLinesSeq(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("one\r\n\x1b[31mtwo\r\nthree")), nil)
+	count := 0
+	for line := range d.LinesSeq(ansibump.CGA16) {
+		be.Equal(t, line, d.Lines(ansibump.CGA16)[count])
+		count++
+	}
+	be.Equal(t, count, 3)
+	// stop early
+	for line := range d.LinesSeq(ansibump.CGA16) {
+		be.Equal(t, line, `<span style="color:#aaa;">one</span>`)
+		break
+	}
+}
+
+func TestDecodeLine(t *testing.T) {
+	t.Parallel()
+	lines := []string{"\x1b[31mred", "still red \x1b[32mgreen", "\x1b[0mplain"}
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	var b strings.Builder
+	for _, line := range lines {
+		s, err := d.DecodeLine([]byte(line))
+		be.Err(t, err, nil)
+		b.WriteString(s + "\n")
+	}
+	be.Equal(t, b.String(), `<span style="color:#a00;">red</span>`+"\n"+
+		`<span style="color:#a00;">still red </span><span style="color:#0a0;">green</span>`+"\n"+
+		`<span style="color:#aaa;">plain</span>`+"\n")
+}
+
+func TestAutoWidth(t *testing.T) {
+	t.Parallel()
+	ansi := strings.Repeat("x", 90) + "\x1b[5C" + strings.Repeat("y", 5) + "\r\nab\tc"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	be.Equal(t, len(d.Cells()), 3)
+
+	cust.AutoWidth = true
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells), 2)
+	be.Equal(t, len(cells[0]), 100)
+
+	// wide characters use two columns and combining marks none
+	wide := strings.Repeat("漢", 45) + "e\u0301"
+	cust = ansibump.Customizer{CharSet: nil, AutoWidth: true}
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(wide)), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 1)
+	be.Equal(t, len(cells[0]), 91)
+
+	// the OSC title and the character set designations use no columns
+	cust = ansibump.Customizer{Width: 4, AutoWidth: true, BackgroundColorErase: true}
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b]0;The title\x07\x1b(0\x1b(Bab\x1b[44m\x1b[K")), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 1)
+	be.Equal(t, len(cells[0]), 4)
+	// the code page controls are written as characters that use a column each
+	cust = ansibump.Customizer{Width: 4, AutoWidth: true, CharSet: charmap.CodePage437}
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(strings.Repeat("\x03", 6))), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 1)
+	be.Equal(t, len(cells[0]), 6)
+
+	// a reused decoder measures each text from the configured width
+	cust = ansibump.Customizer{Width: 4, AutoWidth: true, BackgroundColorErase: true}
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("abcdefgh")), nil)
+	be.Equal(t, len(d.Cells()), 1)
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("ab\r\nabcdef")), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 2)
+	be.Equal(t, len(cells[1]), 6)
+	d.Reset()
+	be.Err(t, d.Read(strings.NewReader("ab\x1b[44m\x1b[K")), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 1)
+	be.Equal(t, len(cells[0]), 4)
+}
+
+func TestNoWrap(t *testing.T) {
+	t.Parallel()
+	ansi := strings.Repeat("x", 300) + "\ty"
+	s, err := ansibump.String(strings.NewReader(ansi), 80)
+	be.Err(t, err, nil)
+	be.Equal(t, strings.Count(s, "\n"), 3)
+
+	s, err = ansibump.String(strings.NewReader(ansi), ansibump.NoWrap)
+	be.Err(t, err, nil)
+	be.Equal(t, strings.Count(s, "\n"), 0)
+	be.True(t, strings.Contains(s, strings.Repeat("x", 300)+"    y"))
+}
+
+func TestTailwind(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[1;31;40mX\x1b[0;4;42mY"
+	cust := ansibump.Customizer{Tailwind: true, BoldMode: ansibump.BoldBoth}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div class="text-[#aaa] bg-[#000]">`+
+		`<span class="text-[#f55] bg-[#000] font-bold">X</span>`+
+		`<span class="text-[#aaa] bg-[#0a0] underline">Y</span></div>`)
+}
+
+func TestBoldMode(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[1;31mX"
+	tests := []struct {
+		mode ansibump.BoldMode
+		want string
+	}{
+		{ansibump.BoldBright, `<span style="color:#f55;">X</span>`},
+		{ansibump.BoldWeight, `<span style="color:#a00;font-weight:bold;">X</span>`},
+		{ansibump.BoldBoth, `<span style="color:#f55;font-weight:bold;">X</span>`},
+	}
+	for _, tt := range tests {
+		cust := ansibump.Customizer{BoldMode: tt.mode}
+		buf, err := cust.Buffer(strings.NewReader(ansi))
+		be.Err(t, err, nil)
+		be.True(t, strings.Contains(buf.String(), tt.want))
+	}
+	cust := ansibump.Customizer{Tailwind: true}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), `<span class="text-[#f55] bg-[#000]">X</span>`))
+}
+
+func TestBufferAuto(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[1;31mHello\x1b[0m world\r\n"
+	cust := ansibump.Customizer{}
+	plain, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err = zw.Write([]byte(ansi))
+	be.Err(t, err, nil)
+	be.Err(t, zw.Close(), nil)
+	buf, err := cust.BufferAuto(&gz)
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), plain.String())
+
+	buf, err = cust.BufferAuto(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), plain.String())
+
+	_, err = cust.BufferAuto(nil)
+	be.Err(t, err, ansibump.ErrReader)
+}
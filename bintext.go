@@ -0,0 +1,75 @@
+package ansibump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// DecodeBinText decodes the BinText (.bin) format, which is raw pairs of a character and an attribute byte
+// with a fixed width and no escape sequences. If width is <= 0, a 160 columns value is used,
+// the common width of BinText. A SAUCE metadata record at the end of the text is ignored.
+//
+// The characters use IBM Code Page 437 encoding. In the attribute byte,
+// bits 0 to 2 are the foreground color, bit 3 is bold for the bright foreground,
+// bits 4 to 6 are the background color, and bit 7 is the blink bit,
+// which is shown as a bright background as with the iCE colors of most viewers.
+// The returned Decoder uses the rendering methods, such as [Decoder.Write].
+func DecodeBinText(r io.Reader, width int, pal Palette) (*Decoder, error) {
+	if r == nil {
+		return nil, ErrReader
+	}
+	p, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("decode bin text read all: %w", err)
+	}
+	p = trimSauce(p)
+	const columns = 160
+	if width <= 0 {
+		width = columns
+	}
+	// the rows are started by the cell count instead of autowrap,
+	// so a final full row does not wrap the cursor to an empty row
+	cust := Customizer{Width: width, Color: pal, CharSet: charmap.CodePage437, NoAutowrap: true}
+	d := cust.NewDecoder()
+	const pair = 2
+	for i := 0; i+1 < len(p); i += pair {
+		if n := i / pair; n > 0 && n%width == 0 {
+			d.newline()
+		}
+		char, attr := p[i], p[i+1]
+		ch := d.decode(char)
+		if char == NUL {
+			ch = ' '
+		}
+		d.writeRune(ch, binAttr(attr, pal))
+	}
+	return d, nil
+}
+
+// binAttr returns the Attribute of the BinText attribute byte.
+// The attribute uses the color order of the IBM PC, where 1 is blue and 4 is red,
+// which is mapped to the order of the ANSI colors.
+func binAttr(b byte, pal Palette) Attribute {
+	const bold, blink = 0x08, 0x80
+	pc := [8]int{0, 4, 2, 6, 1, 5, 3, 7}
+	fg := int(b & 0x07)      //nolint:mnd
+	bg := int(b >> 4 & 0x07) //nolint:mnd
+	return Attribute{
+		FG:   BasicHex(pc[fg], false, pal),
+		BG:   BasicHex(pc[bg], b&blink != 0, pal),
+		Bold: b&bold != 0,
+	}
+}
+
+// trimSauce returns the text without the SAUCE metadata record and its end-of-file marker.
+func trimSauce(p []byte) []byte {
+	const size = 128
+	if len(p) < size || !bytes.HasPrefix(p[len(p)-size:], []byte("SAUCE00")) {
+		return p
+	}
+	p = p[:len(p)-size]
+	return bytes.TrimSuffix(p, []byte{EOF})
+}
@@ -0,0 +1,39 @@
+package ansibump_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bengarrett/ansibump"
+	"github.com/nalgeon/be"
+)
+
+func TestDecodeBinText(t *testing.T) {
+	t.Parallel()
+	// red on black, bold yellow on blue, white on bright red, then a second row
+	bin := []byte{'H', 0x04, 'i', 0x1e, 0xdb, 0xc7, 'x', 0x07}
+	sauce := append([]byte{ansibump.EOF, 'S', 'A', 'U', 'C', 'E', '0', '0'}, bytes.Repeat([]byte{' '}, 121)...)
+	d, err := ansibump.DecodeBinText(bytes.NewReader(append(bin, sauce...)), 3, ansibump.CGA16)
+	be.Err(t, err, nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#a00;">H</span>` +
+			`<span style="color:#ff5;background-color:#00a;">i</span>` +
+			`<span style="color:#aaa;background-color:#f55;">█</span>`,
+		`<span style="color:#aaa;">x</span>`,
+	})
+
+	_, err = ansibump.DecodeBinText(nil, 0, ansibump.CGA16)
+	be.Err(t, err, ansibump.ErrReader)
+	d, err = ansibump.DecodeBinText(strings.NewReader("a\x07"), 0, ansibump.CGA16)
+	be.Err(t, err, nil)
+	be.Equal(t, len(d.Cells()[0]), 1)
+
+	// an exact multiple of the width has no empty row after the final full row
+	d, err = ansibump.DecodeBinText(strings.NewReader("a\x07b\x07c\x07d\x07"), 2, ansibump.CGA16)
+	be.Err(t, err, nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">ab</span>`,
+		`<span style="color:#aaa;">cd</span>`,
+	})
+}
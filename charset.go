@@ -0,0 +1,110 @@
+package ansibump
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// DetectCharset returns the best guess of the character encoding used by the text.
+//
+// Text that is valid UTF-8 and contains multi-byte sequences returns nil, which is the UTF-8 encoding
+// of the [Customizer] CharSet. Otherwise the high-bit bytes are counted, where the box-drawing and
+// block characters of 0xB0 to 0xDF suggest [charmap.CodePage437] and the lower case
+// accented letters of 0xE0 to 0xFF suggest [charmap.ISO8859_1].
+// Plain ASCII text returns [charmap.CodePage437], the charset of most ANSI art.
+//
+// It is a heuristic, so a text with few high-bit bytes may be misidentified.
+func DetectCharset(p []byte) *charmap.Charmap {
+	ascii := true
+	for _, b := range p {
+		if b >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return charmap.CodePage437
+	}
+	if utf8.Valid(p) {
+		return nil
+	}
+	blocks, letters := 0, 0
+	for _, b := range p {
+		switch {
+		case b >= 0xb0 && b <= 0xdf:
+			blocks++
+		case b >= 0xe0:
+			letters++
+		}
+	}
+	if letters > blocks {
+		return charmap.ISO8859_1
+	}
+	return charmap.CodePage437
+}
+
+// petsciiGraphics are the characters of the Commodore PETSCII bytes 0x60 to 0x7f
+// and 0xa0 to 0xbf using the unshifted, upper case and graphics, character set.
+// Many of the graphics use the Unicode Symbols for Legacy Computing block.
+var petsciiGraphics = [64]rune{ //nolint:gochecknoglobals
+	// 0x60 to 0x7f
+	'─', '♠', '\U0001FB72', '\U0001FB78', '\U0001FB77', '\U0001FB76', '\U0001FB7A', '\U0001FB71',
+	'\U0001FB74', '╮', '╰', '╯', '\U0001FB7C', '╲', '╱', '\U0001FB7D',
+	'\U0001FB7E', '●', '\U0001FB7B', '♥', '\U0001FB70', '╭', '╳', '○',
+	'♣', '\U0001FB75', '♦', '┼', '\U0001FB8C', '│', 'π', '◥',
+	// 0xa0 to 0xbf
+	' ', '▌', '▄', '▔', '▁', '▏', '▒', '▕',
+	'\U0001FB8F', '◤', '\U0001FB87', '├', '▗', '└', '┐', '▂',
+	'┌', '┴', '┬', '┤', '▎', '▍', '\U0001FB88', '\U0001FB82',
+	'\U0001FB83', '▃', '\U0001FB7F', '▖', '▝', '┘', '▘', '▚',
+}
+
+// petscii returns the Unicode character of the Commodore 64 PETSCII byte,
+// using the unshifted character set of upper case letters and graphics.
+// The PETSCII control codes 0x80 to 0x9f, which set colors and move the cursor, return a space.
+func petscii(b byte) rune {
+	switch {
+	case b <= 0x5b, b == 0x5d:
+		return rune(b)
+	case b == 0x5c:
+		return '£'
+	case b == 0x5e:
+		return '↑'
+	case b == 0x5f:
+		return '←'
+	case b < 0x80:
+		return petsciiGraphics[b-0x60]
+	case b < 0xa0:
+		return ' '
+	case b < 0xc0:
+		return petsciiGraphics[b-0x80]
+	case b < 0xe0:
+		// repeats 0x60 to 0x7f
+		return petsciiGraphics[b-0xc0]
+	case b == 0xff:
+		return 'π'
+	default:
+		// repeats 0xa0 to 0xbe
+		return petsciiGraphics[b-0xc0]
+	}
+}
+
+// decGraphics is the final byte of the ESC ( 0 sequence that designates the DEC Special Graphics set.
+const decGraphics = '0'
+
+// decLineDrawing are the characters of the DEC Special Graphics set for the bytes 0x5f to 0x7e,
+// which are mostly used to draw lines and boxes.
+var decLineDrawing = [32]rune{ //nolint:gochecknoglobals
+	' ', '◆', '▒', '␉', '␌', '␍', '␊', '°', '±', '␤', '␋', '┘', '┐', '┌', '└', '┼',
+	'⎺', '⎻', '─', '⎼', '⎽', '├', '┤', '┴', '┬', '│', '≤', '≥', 'π', '≠', '£', '·',
+}
+
+// lineDrawing returns the DEC Special Graphics character of the byte.
+// The ok result is false for bytes that are unchanged by the set.
+func lineDrawing(b byte) (rune, bool) {
+	if b < 0x5f || b > 0x7e {
+		return 0, false
+	}
+	return decLineDrawing[b-0x5f], true
+}
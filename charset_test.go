@@ -0,0 +1,150 @@
+package ansibump_test
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bengarrett/ansibump"
+	"github.com/nalgeon/be"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestDetectCharset(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		p    string
+		want *charmap.Charmap
+	}{
+		{"ascii", "\x1b[1;31mHello world\x1b[0m", charmap.CodePage437},
+		{"utf-8", "\x1b[32mcafé ░▒▓█\x1b[0m", nil},
+		{"cp437", "\x1b[34m\xda\xc4\xc4\xbf\r\n\xb3\xb0\xb1\xb2\xdb\xb3\r\n\xc0\xc4\xc4\xd9", charmap.CodePage437},
+		{"latin-1", "caf\xe9 cr\xe8me br\xfbl\xe9e \xe0 la fran\xe7aise", charmap.ISO8859_1},
+		{"empty", "", charmap.CodePage437},
+	}
+	for _, tt := range tests {
+		be.Equal(t, ansibump.DetectCharset([]byte(tt.p)), tt.want)
+	}
+}
+
+func TestAutoCharset(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{AutoCharset: true}
+	buf, err := cust.Buffer(strings.NewReader("\xb0\xb1\xb2"))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), ">░▒▓</span>"))
+	buf, err = cust.Buffer(strings.NewReader("caf\xe9"))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), ">café</span>"))
+}
+
+func TestPETSCII(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{PETSCII: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("C64\x5c\x5e\x71\x73\xa6\xd3\xff")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">C64£↑●♥▒♥π</span>`})
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("READY.\rRUN")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">READY.</span>`,
+		`<span style="color:#aaa;">RUN</span>`,
+	})
+}
+
+func TestDECLineDrawing(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b(0lqk\r\nx x\r\nmqj\x1b(Blqk")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">┌─┐</span>`,
+		`<span style="color:#aaa;">│ │</span>`,
+		`<span style="color:#aaa;">└─┘lqk</span>`,
+	})
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b(Z")), ansibump.ErrUnknownEsc)
+}
+
+func TestShiftOut(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Strict: true}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b)0lq\x0elqk\x0fk\x0ex")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">lq┌─┐k│</span>`})
+	// code pages keep the shift controls as characters
+	cust.CharSet = charmap.CodePage437
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b)0\x0elq\x0f")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{"<span style=\"color:#aaa;\">\x0elq\x0f</span>"})
+}
+
+func TestWideCharacters(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{Width: 6}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("你好!\r\x1b[4Cz")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{`<span style="color:#aaa;">你好z</span>`})
+	cells := d.Cells()
+	be.Equal(t, len(cells[0]), 5)
+	be.Equal(t, cells[0][0].Char, "你")
+	be.Equal(t, cells[0][1].Char, "")
+	be.Equal(t, cells[0][2].Char, "好")
+
+	// a wide character that does not fit wraps to the next line
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("abcde你")), nil)
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		`<span style="color:#aaa;">abcde</span>`,
+		`<span style="color:#aaa;">你</span>`,
+	})
+}
+
+func TestWideFill(t *testing.T) {
+	t.Parallel()
+	const text = "你好!\r\nab"
+	tests := []struct {
+		fill  rune
+		want  string
+		runes int
+	}{
+		{0, "你好!\nab", 3},
+		{' ', "你 好 !\nab", 5},
+		{'.', "你.好.!\nab", 5},
+	}
+	for _, tt := range tests {
+		cust := ansibump.Customizer{WideFill: tt.fill}
+		d := cust.NewDecoder()
+		be.Err(t, d.Read(strings.NewReader(text)), nil)
+		be.Equal(t, d.Text(), tt.want)
+		line, _, _ := strings.Cut(d.Text(), "\n")
+		be.Equal(t, utf8.RuneCountInString(line), tt.runes)
+		be.Equal(t, d.Lines(ansibump.CGA16)[0], `<span style="color:#aaa;">你好!</span>`)
+	}
+}
+
+func TestCombiningCharacters(t *testing.T) {
+	t.Parallel()
+	// the acute accent follows the e, after it wraps the cursor to the next line
+	cust := ansibump.Customizer{Width: 4}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("cafe\u0301!\x1b[2b")), nil)
+	cells := d.Cells()
+	be.Equal(t, len(cells[0]), 4)
+	be.Equal(t, cells[0][3].Char, "e\u0301")
+	be.Equal(t, d.Lines(ansibump.CGA16), []string{
+		"<span style=\"color:#aaa;\">cafe\u0301</span>",
+		`<span style="color:#aaa;">!!!</span>`,
+	})
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("ne\u0301e")), nil)
+	be.Equal(t, len(d.Cells()[0]), 3)
+	// a new line does not attach the accent to the character of the previous line
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("e\r\n\u0301")), nil)
+	cells = d.Cells()
+	be.Equal(t, len(cells), 2)
+	be.Equal(t, cells[0][0].Char, "e")
+	be.Equal(t, cells[1][0].Char, "\u0301")
+}
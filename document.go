@@ -0,0 +1,208 @@
+package ansibump
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+var (
+	ErrFont      = errors.New("font family contains invalid characters or the size is negative")
+	ErrDirection = errors.New("direction must be either ltr or rtl")
+	ErrImageURL  = errors.New("background image must be a relative or data URL")
+)
+
+// WithFont sets the CSS font-family and font-size in pixels of the outer wrapper element of the HTML,
+// for example WithFont("Cascadia Mono, monospace", 16).
+// When a family is empty or a size is 0, that property is not set and a generic monospace font is used.
+//
+// To prevent CSS injection, the family may only contain letters, digits, spaces, hyphens,
+// underscores and commas, otherwise the ErrFont error is returned.
+func (c *Customizer) WithFont(family string, sizePx int) error {
+	family = strings.TrimSpace(family)
+	valid := func(r rune) bool {
+		return ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') ||
+			r == ' ' || r == '-' || r == '_' || r == ','
+	}
+	for _, r := range family {
+		if !valid(r) {
+			return fmt.Errorf("%w: %q", ErrFont, family)
+		}
+	}
+	if sizePx < 0 {
+		return fmt.Errorf("%w: %dpx", ErrFont, sizePx)
+	}
+	c.fontFamily = family
+	c.fontSize = sizePx
+	return nil
+}
+
+// WithDirection sets the dir attribute of the outer wrapper element of the HTML,
+// which is either "ltr" for left-to-right or "rtl" for right-to-left text.
+// An empty string removes the attribute, while other values return the ErrDirection error.
+func (c *Customizer) WithDirection(dir string) error {
+	switch dir {
+	case "", "ltr", "rtl":
+		c.dir = dir
+		return nil
+	}
+	return fmt.Errorf("%w: %q", ErrDirection, dir)
+}
+
+// WithBackgroundImage sets the CSS background-image of the outer wrapper element of the HTML,
+// for example a scanline pattern behind the text with WithBackgroundImage("img/scanlines.png").
+// An empty string removes the image.
+//
+// To prevent CSS injection and the loading of remote content, the URL must either be a relative URL
+// or a data URL of an image, such as "data:image/png;base64,...", and cannot contain quotes,
+// parentheses, backslashes, angle brackets, ampersands or whitespace. Otherwise the ErrImageURL error is returned.
+func (c *Customizer) WithBackgroundImage(url string) error {
+	if url == "" {
+		c.background = ""
+		return nil
+	}
+	if strings.ContainsAny(url, "'\"()\\<>&") || strings.ContainsFunc(url, unicode.IsSpace) {
+		return fmt.Errorf("%w: %q", ErrImageURL, url)
+	}
+	data := strings.HasPrefix(strings.ToLower(url), "data:image/")
+	// a relative URL has no scheme, so any colon follows the path, query or fragment
+	scheme, _, found := strings.Cut(url, ":")
+	relative := !strings.HasPrefix(url, "//") && (!found || strings.ContainsAny(scheme, "/?#"))
+	if !data && !relative {
+		return fmt.Errorf("%w: %q", ErrImageURL, url)
+	}
+	c.background = url
+	return nil
+}
+
+// WithTrailingNewline writes a newline after the closing element of the outer wrapper when true,
+// which gives diff-stable output for files. By default there is no trailing newline.
+func (c *Customizer) WithTrailingNewline(newline bool) {
+	c.trailingNewline = newline
+}
+
+// WithARIA describes the outer wrapper element to screen readers as an image with the label,
+// using the role="img" and aria-label attributes. When the label is empty,
+// the window title set by an OSC sequence in the text is used.
+// The spans that only contain spaces, box-drawing and block characters are marked as aria-hidden,
+// as they are decorative.
+func (c *Customizer) WithARIA(label string) {
+	c.aria = true
+	c.ariaLabel = label
+}
+
+// backgroundImage returns the CSS background-image property set by WithBackgroundImage.
+func (c *Customizer) backgroundImage() string {
+	if c.background == "" {
+		return ""
+	}
+	return "background-image:url('" + c.background + "');"
+}
+
+// font returns the CSS font properties set by WithFont.
+func (c *Customizer) font() string {
+	s := ""
+	if c.fontFamily != "" {
+		s += "font-family:" + c.fontFamily + ";"
+	}
+	if c.fontSize > 0 {
+		s += "font-size:" + strconv.Itoa(c.fontSize) + "px;"
+	}
+	return s
+}
+
+// CSSVarDefaults returns a :root stylesheet rule that declares the CSS custom properties
+// used by the CSSVars mode of the [Customizer], using the colors of the CGA16 palette.
+// The --ansi-fg and --ansi-bg properties are the default colors, and --ansi-0 to --ansi-15 are the base colors.
+func CSSVarDefaults() string {
+	colors := CGA()
+	var b strings.Builder
+	b.WriteString(":root {")
+	b.WriteString("--ansi-fg:#" + string(colors.DefaultFG()) + ";")
+	b.WriteString("--ansi-bg:#" + string(colors.DefaultBG()) + ";")
+	b.WriteString(CGA16.CSS())
+	b.WriteString("}")
+	return b.String()
+}
+
+// CSS returns the 16 colors of the palette as CSS custom properties, --ansi-0 to --ansi-15,
+// for example "--ansi-0:#000;--ansi-1:#a00;" for the start of CGA16.
+// The properties can be placed in a rule to theme a page that uses the CSSVars mode of the [Customizer].
+// An empty string is returned for an unknown palette.
+func (p Palette) CSS() string {
+	var b strings.Builder
+	const colors = 16
+	for i := range colors {
+		c := BasicHex(i%8, i >= 8, p) //nolint:mnd
+		if c == "" {
+			return ""
+		}
+		b.WriteString("--ansi-" + strconv.Itoa(i) + ":#" + c + ";")
+	}
+	return b.String()
+}
+
+// Document returns a complete, standalone HTML document containing the HTML elements
+// of the ANSI encoded text found in the Reader, which is useful for quick previews.
+// The title is used for the title element of the document.
+// It assumes the Reader is using IBM Code Page 437 encoding.
+// If width is <= 0, an 80 columns value is used, except for [NoWrap] which never wraps the text.
+func Document(r io.Reader, title string, width int) (string, error) {
+	cust := Customizer{
+		Width:       width,
+		AmigaParser: false,
+		Strict:      false,
+		Color:       CGA16,
+		CharSet:     charmap.CodePage437,
+	}
+	return cust.Document(r, title)
+}
+
+// Document returns a complete, standalone HTML document containing the HTML elements
+// of the ANSI encoded text found in the Reader, which is useful for quick previews.
+// The title is used for the title element of the document.
+//
+// The parser configurations and arguments are configured using the [Customizer].
+func (c *Customizer) Document(r io.Reader, title string) (string, error) {
+	d, err := c.decode(r)
+	if err != nil {
+		return "", err
+	}
+	// the page uses the same effective default colors as the outer div
+	def := d.style(d.palette)
+	defFg, defBg := def.colors()
+	font := "font-family:monospace;"
+	if c.fontFamily != "" {
+		font = "font-family:" + c.fontFamily + ";"
+	}
+	if c.fontSize > 0 {
+		font += "font-size:" + strconv.Itoa(c.fontSize) + "px;"
+	}
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	b.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	b.WriteString("<style>\n")
+	if c.CSSVars {
+		b.WriteString(CSSVarDefaults() + "\n")
+	}
+	b.WriteString("body {" + def.finish(def.backgroundCSS(defBg)+def.colorCSS(defFg)) + font + "}\n")
+	b.WriteString("</style>\n</head>\n<body>\n")
+	// there is no pre element, so the outer div preserves the spaces of the text
+	if err := d.writeOpen(&b, "white-space:pre;"); err != nil {
+		return "", err
+	}
+	if err := d.writeLines(&b, "\n"); err != nil {
+		return "", err
+	}
+	if err := d.writeClose(&b); err != nil {
+		return "", err
+	}
+	b.WriteString("\n</body>\n</html>\n")
+	return b.String(), nil
+}
@@ -0,0 +1,164 @@
+package ansibump_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bengarrett/ansibump"
+	"github.com/nalgeon/be"
+	"golang.org/x/text/encoding/charmap"
+)
+
+func TestDocument(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[0m\x1b[5;30;42mHI\x1b[0m"
+	doc, err := ansibump.Document(strings.NewReader(ansi), "<Hi & bye>", 80)
+	be.Err(t, err, nil)
+	be.True(t, strings.HasPrefix(doc, "<!DOCTYPE html>\n"))
+	be.True(t, strings.Contains(doc, "<title>&lt;Hi &amp; bye&gt;</title>"))
+	be.True(t, strings.Contains(doc, "body {background-color:#000;color:#aaa;font-family:monospace;}"))
+	be.True(t, !strings.Contains(doc, "<pre>"))
+	be.True(t, strings.Contains(doc, `<div style="color:#aaa;background-color:#000;white-space:pre;">`))
+
+	cust := ansibump.Customizer{InvertAll: true}
+	doc, err = cust.Document(strings.NewReader("HI"), "")
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(doc, "body {background-color:#aaa;color:#000;font-family:monospace;}"))
+	cust = ansibump.Customizer{CSSVars: true}
+	doc, err = cust.Document(strings.NewReader("HI"), "")
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(doc, ansibump.CSSVarDefaults()))
+	be.True(t, strings.Contains(doc, "body {background-color:var(--ansi-bg);color:var(--ansi-fg);font-family:monospace;}"))
+
+	_, err = ansibump.Document(nil, "", 80)
+	be.Err(t, err, ansibump.ErrReader)
+}
+
+func TestWithFont(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	err := cust.WithFont("monospace; background:url(x)", 16)
+	be.Err(t, err, ansibump.ErrFont)
+	err = cust.WithFont(`mono" onclick="x`, 16)
+	be.Err(t, err, ansibump.ErrFont)
+	err = cust.WithFont("Cascadia Mono", -1)
+	be.Err(t, err, ansibump.ErrFont)
+
+	err = cust.WithFont("Cascadia Mono, monospace", 16)
+	be.Err(t, err, nil)
+	buf, err := cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;`+
+		`font-family:Cascadia Mono, monospace;font-size:16px;"><span style="color:#aaa;">HI</span></div>`)
+	doc, err := cust.Document(strings.NewReader("HI"), "")
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(doc, "body {background-color:#000;color:#aaa;"+
+		"font-family:Cascadia Mono, monospace;font-size:16px;}"))
+
+	cust.Minify = true
+	buf, err = cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;`+
+		`font-family:Cascadia Mono, monospace;font-size:16px"><span>HI</span></div>`)
+}
+
+func TestWithDirection(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	be.Err(t, cust.WithDirection("up"), ansibump.ErrDirection)
+	be.Err(t, cust.WithDirection(`rtl" onclick="x`), ansibump.ErrDirection)
+	be.Err(t, cust.WithDirection("rtl"), nil)
+	buf, err := cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div dir="rtl" style="color:#aaa;background-color:#000;"><span style="color:#aaa;">HI</span></div>`)
+	doc, err := cust.Document(strings.NewReader("HI"), "")
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(doc, "<body>\n<div dir=\"rtl\" "))
+	be.Err(t, cust.WithDirection(""), nil)
+	buf, err = cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.True(t, strings.HasPrefix(buf.String(), `<div style=`))
+}
+
+func TestWithBackgroundImage(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	for _, url := range []string{
+		"javascript:alert(1)", "JavaScript:x", "https://example.com/a.png", "//example.com/a.png",
+		"a.png') ;color:red", `a.png" onclick="x`, "a b.png", "data:text/html,<b>",
+	} {
+		be.Err(t, cust.WithBackgroundImage(url), ansibump.ErrImageURL)
+	}
+	be.Err(t, cust.WithBackgroundImage("img/scanlines.png?v=1#a:b"), nil)
+	buf, err := cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:#aaa;background-color:#000;`+
+		`background-image:url('img/scanlines.png?v=1#a:b');"><span style="color:#aaa;">HI</span></div>`)
+
+	const data = "data:image/png;base64,iVBORw0KGgo="
+	be.Err(t, cust.WithBackgroundImage(data), nil)
+	buf, err = cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.True(t, strings.Contains(buf.String(), "background-image:url('"+data+"');"))
+	be.Err(t, cust.WithBackgroundImage(""), nil)
+	buf, err = cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.True(t, !strings.Contains(buf.String(), "background-image"))
+}
+
+func TestWithTrailingNewline(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	buf, err := cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.True(t, strings.HasSuffix(buf.String(), "</div>"))
+	cust.WithTrailingNewline(true)
+	buf, err = cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.True(t, strings.HasSuffix(buf.String(), "</span></div>\n"))
+	cust.WithTrailingNewline(false)
+	buf, err = cust.Buffer(strings.NewReader("HI"))
+	be.Err(t, err, nil)
+	be.True(t, strings.HasSuffix(buf.String(), "</div>"))
+}
+
+func TestWithARIA(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b]0;Title\x07\x1b[31mHI\x1b[0m \xb2\xb2"
+	cust := ansibump.Customizer{CharSet: charmap.CodePage437}
+	cust.WithARIA(`A "red" greeting`)
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div role="img" aria-label="A &#34;red&#34; greeting" style="color:#aaa;background-color:#000;">`+
+		`<span style="color:#a00;">HI</span><span aria-hidden="true" style="color:#aaa;"> ▓▓</span></div>`)
+
+	cust.WithARIA("")
+	buf, err = cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.True(t, strings.HasPrefix(buf.String(), `<div role="img" aria-label="Title" style=`))
+}
+
+func TestCSSVars(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31mred\x1b[0m \x1b[1;44mblue\x1b[0;38;5;208mxterm"
+	cust := ansibump.Customizer{CSSVars: true}
+	buf, err := cust.Buffer(strings.NewReader(ansi))
+	be.Err(t, err, nil)
+	be.Equal(t, buf.String(), `<div style="color:var(--ansi-fg);background-color:var(--ansi-bg);">`+
+		`<span style="color:var(--ansi-1);">red</span><span style="color:var(--ansi-fg);"> </span>`+
+		`<span style="color:var(--ansi-15);background-color:var(--ansi-4);">blue</span>`+
+		`<span style="color:#ff8700;">xterm</span></div>`)
+	css := ansibump.CSSVarDefaults()
+	be.True(t, strings.HasPrefix(css, ":root {--ansi-fg:#aaa;--ansi-bg:#000;--ansi-0:#000;--ansi-1:#a00;"))
+	be.True(t, strings.HasSuffix(css, "--ansi-15:#fff;}"))
+}
+
+func TestPaletteCSS(t *testing.T) {
+	t.Parallel()
+	css := ansibump.CGA16.CSS()
+	be.True(t, strings.HasPrefix(css, "--ansi-0:#000;--ansi-1:#a00;"))
+	be.True(t, strings.HasSuffix(css, "--ansi-15:#fff;"))
+	be.Equal(t, strings.Count(css, "--ansi-"), 16)
+	be.True(t, strings.Contains(ansibump.Xterm16.CSS(), "--ansi-1:#800000;"))
+	be.Equal(t, ansibump.Palette(99).CSS(), "")
+}
@@ -4,6 +4,7 @@ go 1.25.3
 
 require (
 	github.com/nalgeon/be v0.3.0
+	golang.org/x/image v0.32.0
 	golang.org/x/text v0.30.0
 )
 
@@ -0,0 +1,80 @@
+package ansibump
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+var ErrCellSize = errors.New("cell width and height must be greater than zero")
+
+// Image draws the decoded screen buffer onto a new image,
+// where each character cell is a rectangle of cellW by cellH pixels.
+// The colors of the cells follow the same inverse and bold rules as the HTML output.
+//
+// The characters are drawn using the face, which should be a monospaced font
+// that fits within the cell size. If the face is nil, only the cell background colors are drawn,
+// which can be useful for creating thumbnails.
+func (d *Decoder) Image(cellW, cellH int, face font.Face) (image.Image, error) {
+	if cellW <= 0 || cellH <= 0 {
+		return nil, fmt.Errorf("%w: %dx%d", ErrCellSize, cellW, cellH)
+	}
+	cols := 0
+	for _, line := range d.buffer {
+		cols = max(cols, len(line))
+	}
+	rows := len(d.buffer)
+	img := image.NewRGBA(image.Rect(0, 0, cols*cellW, rows*cellH))
+	def := d.style(d.palette)
+	_, canvas := def.colors()
+	draw.Draw(img, img.Bounds(), image.NewUniform(canvas.rgba()), image.Point{}, draw.Src)
+	drawer := font.Drawer{Dst: img, Face: face}
+	ascent := 0
+	if face != nil {
+		ascent = face.Metrics().Ascent.Ceil()
+	}
+	for y, line := range d.buffer {
+		for x, c := range line {
+			fg, bg := effective(c.Attr, def)
+			if fg == "" {
+				fg = def.fg
+			}
+			if bg == "" {
+				bg = def.bg
+			}
+			rect := image.Rect(x*cellW, y*cellH, (x+1)*cellW, (y+1)*cellH)
+			draw.Draw(img, rect, image.NewUniform(bg.rgba()), image.Point{}, draw.Src)
+			if face == nil || c.Char == ' ' || c.Char == wideSpacer {
+				continue
+			}
+			drawer.Src = image.NewUniform(fg.rgba())
+			drawer.Dot = fixed.P(rect.Min.X, rect.Min.Y+ascent)
+			drawer.DrawString(string(c.Char) + c.marks)
+		}
+	}
+	return img, nil
+}
+
+// rgba returns the color as an opaque RGBA value.
+// Invalid hexadecimal values return black.
+func (c Color) rgba() color.RGBA {
+	black := color.RGBA{A: 0xff}
+	s := string(c)
+	if len(s) == 3 { //nolint:mnd
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 { //nolint:mnd
+		return black
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return black
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff} //nolint:gosec,mnd
+}
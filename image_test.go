@@ -0,0 +1,50 @@
+package ansibump_test
+
+import (
+	"bytes"
+	"flag"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bengarrett/ansibump"
+	"github.com/nalgeon/be"
+	"golang.org/x/image/font/basicfont"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestImage(t *testing.T) {
+	t.Parallel()
+	const ansi = "\x1b[31mR\x1b[1;42mG\x1b[0;7mI"
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader(ansi)), nil)
+
+	_, err := d.Image(0, 13, basicfont.Face7x13)
+	be.Err(t, err, ansibump.ErrCellSize)
+
+	img, err := d.Image(7, 13, basicfont.Face7x13)
+	be.Err(t, err, nil)
+	be.Equal(t, img.Bounds().Dx(), 3*7)
+	be.Equal(t, img.Bounds().Dy(), 13)
+	var b bytes.Buffer
+	be.Err(t, png.Encode(&b, img), nil)
+	name := filepath.Join("testdata", "image.golden.png")
+	if *update {
+		be.Err(t, os.WriteFile(name, b.Bytes(), 0o644), nil)
+	}
+	golden, err := os.ReadFile(name)
+	be.Err(t, err, nil)
+	be.True(t, bytes.Equal(b.Bytes(), golden))
+
+	// without a font face only the backgrounds are drawn
+	img, err = d.Image(1, 1, nil)
+	be.Err(t, err, nil)
+	r, g, bl, _ := img.At(1, 0).RGBA()
+	be.Equal(t, []uint32{r >> 8, g >> 8, bl >> 8}, []uint32{0x00, 0xaa, 0x00})
+	r, g, bl, _ = img.At(2, 0).RGBA()
+	be.Equal(t, []uint32{r >> 8, g >> 8, bl >> 8}, []uint32{0xaa, 0xaa, 0xaa})
+}
@@ -0,0 +1,53 @@
+package ansibump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// jsonCell is the JSON representation of a character cell.
+type jsonCell struct {
+	Char      string `json:"char"`
+	FG        string `json:"fg"`
+	BG        string `json:"bg"`
+	Bold      bool   `json:"bold"`
+	Underline bool   `json:"underline"`
+	Inverse   bool   `json:"inverse"`
+}
+
+// WriteJSON writes to w the decoded screen buffer as a JSON array of rows,
+// where each row is an array of character cells, for front-ends that render the text with their own components.
+//
+// Each cell is an object with the char, fg, bg, bold, underline and inverse fields.
+// The fg and bg fields are the CSS hex colors to display, after the inverse and bold styles are applied,
+// for example {"char":"X","fg":"#a00","bg":"#000","bold":false,"underline":false,"inverse":false}.
+// The second column of a wide character has an empty char.
+func (d *Decoder) WriteJSON(w io.Writer) error {
+	if w == nil {
+		w = io.Discard
+	}
+	def := d.style(d.palette)
+	rows := make([][]jsonCell, len(d.buffer))
+	for y, line := range d.buffer {
+		rows[y] = make([]jsonCell, len(line))
+		for x, c := range line {
+			fg, bg := effective(c.Attr, def)
+			if bg == "" {
+				bg = def.bg
+			}
+			rows[y][x] = jsonCell{
+				Char:      d.char(c),
+				FG:        "#" + string(fg),
+				BG:        "#" + string(bg),
+				Bold:      c.Attr.Bold,
+				Underline: c.Attr.Underline || c.Attr.Double,
+				Inverse:   c.Attr.Inverse,
+			}
+		}
+	}
+	if err := json.NewEncoder(w).Encode(rows); err != nil {
+		return fmt.Errorf("write json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package ansibump_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/bengarrett/ansibump"
+	"github.com/nalgeon/be"
+)
+
+func TestWriteJSON(t *testing.T) {
+	t.Parallel()
+	cust := ansibump.Customizer{}
+	d := cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("\x1b[31mX")), nil)
+	var b strings.Builder
+	be.Err(t, d.WriteJSON(&b), nil)
+	be.Equal(t, b.String(),
+		`[[{"char":"X","fg":"#a00","bg":"#000","bold":false,"underline":false,"inverse":false}]]`+"\n")
+
+	d = cust.NewDecoder()
+	be.Err(t, d.Read(strings.NewReader("a\r\n\x1b[1;4;7;34;43mb")), nil)
+	b.Reset()
+	be.Err(t, d.WriteJSON(&b), nil)
+	var rows [][]map[string]any
+	be.Err(t, json.Unmarshal([]byte(b.String()), &rows), nil)
+	be.Equal(t, len(rows), 2)
+	be.Equal(t, rows[1][0], map[string]any{
+		"char": "b", "fg": "#ff5", "bg": "#00a", "bold": true, "underline": true, "inverse": true,
+	})
+}